@@ -0,0 +1,216 @@
+// Package config reúne as configurações da aplicação, lidas de um arquivo
+// YAML opcional, de variáveis de ambiente e de flags da linha de comando.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/mr-utzig/planne-test/models"
+	"github.com/mr-utzig/planne-test/placement"
+	"gopkg.in/yaml.v3"
+)
+
+// Config contém os parâmetros configuráveis da aplicação.
+type Config struct {
+	// Store é o backend de armazenamento: StoreSQLite ou StoreMemory.
+	Store           string        `yaml:"store"`
+	DBPath          string        `yaml:"db_path"`
+	ListenAddr      string        `yaml:"listen_addr"`
+	JanitorInterval time.Duration `yaml:"janitor_interval"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// PlacementStrategy é a estratégia padrão da alocação automática de frutas.
+	PlacementStrategy string `yaml:"placement_strategy"`
+	// MarkdownRules são as regras de remarcação das frutas próximas da
+	// expiração. Sem regras, o preço efetivo é o preço base.
+	MarkdownRules models.Markdown `yaml:"markdown_rules"`
+}
+
+// Backends de armazenamento aceitos em Config.Store.
+const (
+	// StoreSQLite guarda os dados no arquivo em DBPath.
+	StoreSQLite = "sqlite"
+	// StoreMemory guarda os dados apenas em memória, perdidos ao encerrar;
+	// serve para instâncias descartáveis de demonstração.
+	StoreMemory = "memory"
+)
+
+// Nomes das variáveis de ambiente reconhecidas.
+const (
+	EnvConfigFile      = "FRUIT_CONFIG"
+	EnvStore           = "FRUIT_STORE"
+	EnvDBPath          = "FRUIT_DB_PATH"
+	EnvListenAddr      = "FRUIT_LISTEN_ADDR"
+	EnvJanitorInterval = "FRUIT_JANITOR_INTERVAL"
+	EnvShutdownTimeout = "FRUIT_SHUTDOWN_TIMEOUT"
+	EnvPlacement       = "FRUIT_PLACEMENT_STRATEGY"
+	EnvMarkdownRules   = "FRUIT_MARKDOWN_RULES"
+)
+
+// Default devolve a configuração usada quando nada é informado.
+func Default() Config {
+	return Config{
+		Store:             StoreSQLite,
+		DBPath:            "./fruit_buckets.db",
+		ListenAddr:        ":8080",
+		JanitorInterval:   1 * time.Minute,
+		ShutdownTimeout:   10 * time.Second,
+		PlacementStrategy: placement.NameFirstFit,
+	}
+}
+
+// Load monta a configuração a partir dos valores padrão, do arquivo YAML
+// (se informado), das variáveis de ambiente e das flags, nessa ordem de
+// precedência crescente. Devolve também os argumentos que sobraram após as
+// flags, usados para subcomandos.
+func Load(args []string, getenv func(string) string) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("planne-test", flag.ContinueOnError)
+	configFile := fs.String("config", getenv(EnvConfigFile), "arquivo de configuração YAML (env "+EnvConfigFile+")")
+	store := fs.String("store", cfg.Store, "backend de armazenamento, sqlite ou memory (env "+EnvStore+")")
+	dbPath := fs.String("db", cfg.DBPath, "caminho do arquivo SQLite (env "+EnvDBPath+")")
+	listenAddr := fs.String("addr", cfg.ListenAddr, "endereço em que o servidor escuta (env "+EnvListenAddr+")")
+	janitorInterval := fs.Duration("janitor-interval", cfg.JanitorInterval, "intervalo máximo entre varreduras de frutas expiradas (env "+EnvJanitorInterval+")")
+	shutdownTimeout := fs.Duration("shutdown-timeout", cfg.ShutdownTimeout, "tempo máximo para concluir as requisições ao encerrar (env "+EnvShutdownTimeout+")")
+	placementStrategy := fs.String("placement-strategy", cfg.PlacementStrategy, "estratégia padrão de alocação automática de frutas (env "+EnvPlacement+")")
+	markdownRules := fs.String("markdown-rules", cfg.MarkdownRules.String(), "regras de remarcação no formato janela:percentual, como 24h:20,6h:50 (env "+EnvMarkdownRules+")")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	// 1. Arquivo de configuração
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	// 2. Variáveis de ambiente
+	if v := getenv(EnvStore); v != "" {
+		cfg.Store = v
+	}
+	if v := getenv(EnvDBPath); v != "" {
+		cfg.DBPath = v
+	}
+	if v := getenv(EnvListenAddr); v != "" {
+		cfg.ListenAddr = v
+	}
+	if v := getenv(EnvJanitorInterval); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, nil, fmt.Errorf("%s inválido: %w", EnvJanitorInterval, err)
+		}
+		cfg.JanitorInterval = d
+	}
+	if v := getenv(EnvShutdownTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, nil, fmt.Errorf("%s inválido: %w", EnvShutdownTimeout, err)
+		}
+		cfg.ShutdownTimeout = d
+	}
+	if v := getenv(EnvPlacement); v != "" {
+		cfg.PlacementStrategy = v
+	}
+	if v := getenv(EnvMarkdownRules); v != "" {
+		rules, err := models.ParseMarkdown(v)
+		if err != nil {
+			return cfg, nil, fmt.Errorf("%s inválido: %w", EnvMarkdownRules, err)
+		}
+		cfg.MarkdownRules = rules
+	}
+
+	// 3. Flags informadas explicitamente
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "store":
+			cfg.Store = *store
+		case "db":
+			cfg.DBPath = *dbPath
+		case "addr":
+			cfg.ListenAddr = *listenAddr
+		case "janitor-interval":
+			cfg.JanitorInterval = *janitorInterval
+		case "shutdown-timeout":
+			cfg.ShutdownTimeout = *shutdownTimeout
+		case "placement-strategy":
+			cfg.PlacementStrategy = *placementStrategy
+		case "markdown-rules":
+			cfg.MarkdownRules, flagErr = models.ParseMarkdown(*markdownRules)
+		}
+	})
+	if flagErr != nil {
+		return cfg, nil, fmt.Errorf("-markdown-rules inválido: %w", flagErr)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// loadFile sobrepõe a configuração com os valores presentes no arquivo YAML.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("erro ao ler o arquivo de configuração: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("arquivo de configuração %s inválido: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate verifica todos os campos e devolve um erro descrevendo cada valor inválido.
+func (c Config) Validate() error {
+	var errs []error
+
+	switch c.Store {
+	case StoreSQLite:
+		if c.DBPath == "" {
+			errs = append(errs, errors.New("db_path não pode ser vazio"))
+		}
+	case StoreMemory:
+	default:
+		errs = append(errs, fmt.Errorf("store inválido: %q (use %s ou %s)", c.Store, StoreSQLite, StoreMemory))
+	}
+
+	if _, port, err := net.SplitHostPort(c.ListenAddr); err != nil || port == "" {
+		errs = append(errs, fmt.Errorf("listen_addr inválido: %q", c.ListenAddr))
+	}
+
+	if c.JanitorInterval <= 0 {
+		errs = append(errs, fmt.Errorf("janitor_interval deve ser positivo: %s", c.JanitorInterval))
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout deve ser positivo: %s", c.ShutdownTimeout))
+	}
+
+	if _, err := placement.Lookup(c.PlacementStrategy); err != nil {
+		errs = append(errs, fmt.Errorf("placement_strategy inválido: %w", err))
+	}
+
+	if err := c.MarkdownRules.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("markdown_rules inválido: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c Config) String() string {
+	return fmt.Sprintf(
+		"store=%s db_path=%s listen_addr=%s janitor_interval=%s shutdown_timeout=%s placement_strategy=%s markdown_rules=%s",
+		c.Store, c.DBPath, c.ListenAddr, c.JanitorInterval, c.ShutdownTimeout, c.PlacementStrategy, c.MarkdownRules,
+	)
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// TestLoadPrecedence verifica que flags sobrepõem variáveis de ambiente, que
+// sobrepõem o arquivo, que sobrepõe os valores padrão.
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "db_path: /tmp/file.db\nlisten_addr: \":9000\"\njanitor_interval: 5s\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		EnvConfigFile: file,
+		EnvListenAddr: ":9100",
+	}
+
+	cfg, rest, err := Load([]string{"-janitor-interval", "250ms", "migrate", "status"}, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.DBPath != "/tmp/file.db" {
+		t.Errorf("Expected db_path from file. Got %q", cfg.DBPath)
+	}
+	if cfg.ListenAddr != ":9100" {
+		t.Errorf("Expected listen_addr from env. Got %q", cfg.ListenAddr)
+	}
+	if cfg.JanitorInterval != 250*time.Millisecond {
+		t.Errorf("Expected janitor_interval from flag. Got %s", cfg.JanitorInterval)
+	}
+	if len(rest) != 2 || rest[0] != "migrate" || rest[1] != "status" {
+		t.Errorf("Expected remaining args [migrate status]. Got %v", rest)
+	}
+}
+
+// TestLoadDefaults verifica os valores usados quando nada é informado.
+func TestLoadDefaults(t *testing.T) {
+	cfg, _, err := Load(nil, func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Expected defaults %+v. Got %+v", Default(), cfg)
+	}
+}
+
+// TestLoadInvalidValues verifica que valores inválidos são rejeitados.
+func TestLoadInvalidValues(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"empty db path", []string{"-db", ""}, nil},
+		{"unknown store", []string{"-store", "postgres"}, nil},
+		{"unknown env store", nil, map[string]string{EnvStore: "redis"}},
+		{"bad listen addr", []string{"-addr", "8080"}, nil},
+		{"negative interval", []string{"-janitor-interval", "-1s"}, nil},
+		{"zero shutdown timeout", []string{"-shutdown-timeout", "0s"}, nil},
+		{"unknown placement strategy", nil, map[string]string{EnvPlacement: "random"}},
+		{"markdown without percent", []string{"-markdown-rules", "24h"}, nil},
+		{"markdown over 100 percent", nil, map[string]string{EnvMarkdownRules: "6h:120"}},
+		{"repeated markdown window", []string{"-markdown-rules", "6h:20,6h:50"}, nil},
+		{"unparsable env interval", nil, map[string]string{EnvJanitorInterval: "soon"}},
+		{"missing config file", nil, map[string]string{EnvConfigFile: "/does/not/exist.yaml"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Load(tt.args, func(k string) string { return tt.env[k] }); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+// TestLoadMemoryStore verifica a escolha do store em memória, que dispensa o
+// caminho do banco.
+func TestLoadMemoryStore(t *testing.T) {
+	cfg, _, err := Load([]string{"-db", ""}, func(k string) string { return map[string]string{EnvStore: StoreMemory}[k] })
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Store != StoreMemory {
+		t.Errorf("Expected store from env. Got %q", cfg.Store)
+	}
+
+	cfg, _, err = Load([]string{"-store", StoreSQLite}, func(k string) string { return map[string]string{EnvStore: StoreMemory}[k] })
+	if err != nil || cfg.Store != StoreSQLite {
+		t.Errorf("Expected store from flag. Got %q (%v)", cfg.Store, err)
+	}
+}
+
+// TestLoadMarkdownRules verifica as regras de remarcação lidas do arquivo e
+// sobrepostas pela flag.
+func TestLoadMarkdownRules(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "markdown_rules:\n  - within: 24h\n    percent_off: 20\n  - within: 6h\n    percent_off: 50\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, _, err := Load([]string{"-config", file}, func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	want := models.Markdown{{Within: 24 * time.Hour, PercentOff: 20}, {Within: 6 * time.Hour, PercentOff: 50}}
+	if !reflect.DeepEqual(cfg.MarkdownRules, want) {
+		t.Errorf("Expected rules from file %v. Got %v", want, cfg.MarkdownRules)
+	}
+
+	cfg, _, err = Load([]string{"-config", file, "-markdown-rules", "1h:30"}, func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	want = models.Markdown{{Within: time.Hour, PercentOff: 30}}
+	if !reflect.DeepEqual(cfg.MarkdownRules, want) {
+		t.Errorf("Expected rules from flag %v. Got %v", want, cfg.MarkdownRules)
+	}
+}
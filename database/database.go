@@ -6,55 +6,72 @@ import (
 	_ "github.com/mattn/go-sqlite3" // Driver do SQLite
 )
 
-var DB *sql.DB
+// Open abre a conexão com o banco de dados no arquivo informado, sem
+// aplicar migrações.
+func Open(path string) (*sql.DB, error) {
+	// O busy_timeout faz com que escritas concorrentes aguardem o lock do
+	// arquivo em vez de falharem imediatamente com "database is locked". O
+	// _txlock=immediate reserva a escrita já no BEGIN, então transações que
+	// leem antes de escrever não entram em deadlock ao promover o lock.
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
 
-// InitDB inicializa a conexão com o banco de dados e cria as tabelas se não existirem.
-func InitDB() error {
-	var err error
-	DB, err = sql.Open("sqlite3", "./fruit_buckets.db")
+// InitDB inicializa a conexão com o banco de dados no arquivo informado e
+// aplica as migrações pendentes.
+func InitDB(path string) (*sql.DB, error) {
+	db, err := Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err = DB.Ping(); err != nil {
-		return err
+	if _, err := MigrateUp(db); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	return migrate()
+	return db, nil
 }
 
 func InitDBTest() (*sql.DB, error) {
-	var err error
-	DB, err = sql.Open("sqlite3", ":memory:")
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
 	if err != nil {
 		return nil, err
 	}
 
-	if err := migrate(); err != nil {
+	// Cada conexão com ":memory:" abre um banco novo, então o pool
+	// precisa ficar restrito a uma única conexão.
+	db.SetMaxOpenConns(1)
+
+	if _, err := MigrateUp(db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return DB, nil
+	return db, nil
 }
 
-func migrate() error {
-	createTablesSQL := `
-    CREATE TABLE IF NOT EXISTS buckets (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        capacity INTEGER NOT NULL
-    );
-
-    CREATE TABLE IF NOT EXISTS fruits (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        name TEXT NOT NULL,
-        price REAL NOT NULL,
-        expiration_time INTEGER NOT NULL,
-        bucket_id INTEGER,
-        FOREIGN KEY(bucket_id) REFERENCES buckets(id) ON DELETE SET NULL
-    );
-    `
-
-	_, err := DB.Exec(createTablesSQL)
-
-	return err
+// inTx executa fn dentro de uma transação, fazendo commit em caso de sucesso
+// e rollback em caso de erro.
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,948 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// Store é uma implementação em memória de models.BucketStore,
+// models.FruitStore, models.FruitTypeStore e models.WebhookStore, com as
+// mesmas regras do store SQLite. É útil para testes rápidos e instâncias de
+// demonstração. Assim como no SQLite, os valores gravados e devolvidos são
+// cópias: campos ponteiro e slices nunca são compartilhados com quem chama.
+type Store struct {
+	mu sync.RWMutex
+
+	buckets map[int]models.Bucket
+	fruits  map[int]models.Fruit
+	expired []models.ExpiredFruit
+	types   map[int]models.FruitType
+
+	webhooks   map[int]models.Webhook
+	deliveries map[int]models.WebhookDelivery
+
+	// Assim como o AUTOINCREMENT do SQLite, os IDs nunca são reutilizados.
+	nextBucketID   int
+	nextFruitID    int
+	nextTypeID     int
+	nextWebhookID  int
+	nextDeliveryID int
+}
+
+// New cria um store em memória vazio.
+func New() *Store {
+	return &Store{
+		buckets:        make(map[int]models.Bucket),
+		fruits:         make(map[int]models.Fruit),
+		types:          make(map[int]models.FruitType),
+		webhooks:       make(map[int]models.Webhook),
+		deliveries:     make(map[int]models.WebhookDelivery),
+		nextBucketID:   1,
+		nextFruitID:    1,
+		nextTypeID:     1,
+		nextWebhookID:  1,
+		nextDeliveryID: 1,
+	}
+}
+
+func (s *Store) CreateBucket(b *models.Bucket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b.ID = s.nextBucketID
+	s.nextBucketID++
+	s.buckets[b.ID] = cloneBucket(*b)
+
+	return nil
+}
+
+func (s *Store) GetBucket(id int) (models.Bucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.buckets[id]
+	if !ok {
+		return models.Bucket{}, models.ErrBucketNotFound
+	}
+
+	return cloneBucket(b), nil
+}
+
+func (s *Store) ListBuckets() ([]models.Bucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var buckets []models.Bucket
+	for _, b := range s.buckets {
+		buckets = append(buckets, cloneBucket(b))
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].ID < buckets[j].ID
+	})
+
+	return buckets, nil
+}
+
+func (s *Store) ListBucketDetails(filter models.BucketFilter) ([]models.BucketDetails, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// less compara pelo campo de ordenação e desempata pelo ID, na direção
+	// pedida.
+	less := func(a, b models.BucketCursor) bool {
+		if filter.Desc {
+			a, b = b, a
+		}
+		if a.Value != b.Value {
+			return a.Value < b.Value
+		}
+		return a.ID < b.ID
+	}
+
+	var details []models.BucketDetails
+	for _, b := range s.buckets {
+		d := models.NewBucketDetails(cloneBucket(b), s.fruitsInBucket(b.ID))
+
+		if filter.MinOccupancy != 0 && d.Occupancy < filter.MinOccupancy {
+			continue
+		}
+		if filter.Empty != nil && (d.FruitCount == 0) != *filter.Empty {
+			continue
+		}
+		if filter.Full != nil && (d.FruitCount >= d.Capacity) != *filter.Full {
+			continue
+		}
+		if filter.After != nil && !less(*filter.After, d.Cursor(filter)) {
+			continue
+		}
+
+		details = append(details, d)
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		return less(details[i].Cursor(filter), details[j].Cursor(filter))
+	})
+
+	return paginate(details, filter.Limit, 0), nil
+}
+
+func (s *Store) UpdateBucket(id int, update models.UpdateBucketRequest) (models.Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[id]
+	if !ok {
+		return models.Bucket{}, models.ErrBucketNotFound
+	}
+
+	if update.Capacity != nil {
+		if len(s.fruitsInBucket(id)) > *update.Capacity {
+			return models.Bucket{}, models.ErrCapacityBelowCount
+		}
+		bucket.Capacity = *update.Capacity
+	}
+
+	if update.ExpiryWarningSeconds != nil {
+		bucket.ExpiryWarningSeconds = nil
+		if *update.ExpiryWarningSeconds != 0 {
+			seconds := *update.ExpiryWarningSeconds
+			bucket.ExpiryWarningSeconds = &seconds
+		}
+	}
+
+	s.buckets[id] = bucket
+
+	return cloneBucket(bucket), nil
+}
+
+func (s *Store) DeleteBucket(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[id]; !ok {
+		return nil
+	}
+	delete(s.buckets, id)
+
+	// Equivalente ao ON DELETE SET NULL da chave estrangeira.
+	for fid, f := range s.fruits {
+		if f.BucketID.Valid && int(f.BucketID.Int64) == id {
+			f.BucketID = sql.NullInt64{}
+			s.fruits[fid] = f
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) CreateFruit(f *models.Fruit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f.BucketID.Valid {
+		if _, ok := s.buckets[int(f.BucketID.Int64)]; !ok {
+			return models.ErrBucketNotFound
+		}
+	}
+
+	f.ID = s.nextFruitID
+	s.nextFruitID++
+	s.fruits[f.ID] = cloneFruit(*f)
+
+	return nil
+}
+
+func (s *Store) CreateFruits(fruits []models.Fruit) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A carga dos baldes inclui as frutas do lote já aceitas.
+	results := make([]error, len(fruits))
+	b := s.newBatch()
+	failed := false
+
+	for i, f := range fruits {
+		if !f.BucketID.Valid {
+			continue
+		}
+
+		bucketID := int(f.BucketID.Int64)
+		bucket, ok := s.buckets[bucketID]
+		if !ok {
+			results[i], failed = models.ErrBucketNotFound, true
+			continue
+		}
+
+		load := b.load(bucketID)
+		if err := bucket.CheckFit(load, f); err != nil {
+			results[i], failed = err, true
+			continue
+		}
+		load.Add(f)
+		b.loads[bucketID] = load
+	}
+
+	if failed {
+		return results, nil
+	}
+
+	for i := range fruits {
+		fruits[i].ID = s.nextFruitID
+		s.nextFruitID++
+		s.fruits[fruits[i].ID] = cloneFruit(fruits[i])
+	}
+
+	return results, nil
+}
+
+func (s *Store) GetFruit(id int) (models.Fruit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.fruits[id]
+	if !ok {
+		return models.Fruit{}, models.ErrFruitNotFound
+	}
+
+	return cloneFruit(f), nil
+}
+
+func (s *Store) ListFruits(filter models.FruitFilter) ([]models.Fruit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var fruits []models.Fruit
+	for _, f := range s.fruits {
+		if matchesFruitFilter(f, filter) {
+			fruits = append(fruits, cloneFruit(f))
+		}
+	}
+
+	sort.Slice(fruits, func(i, j int) bool {
+		a, b := fruits[i], fruits[j]
+
+		// Como no SQLite, a ordenação por preço agrupa as frutas por moeda,
+		// sempre em ordem alfabética.
+		if filter.SortBy == models.FruitSortPrice && a.Price.Currency != b.Price.Currency {
+			return a.Price.Currency < b.Price.Currency
+		}
+
+		if filter.Desc {
+			a, b = b, a
+		}
+
+		switch filter.SortBy {
+		case models.FruitSortPrice:
+			if a.Price.Amount != b.Price.Amount {
+				return a.Price.Amount < b.Price.Amount
+			}
+		case models.FruitSortName:
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+		case models.FruitSortExpiration:
+			if a.ExpirationTime != b.ExpirationTime {
+				return a.ExpirationTime < b.ExpirationTime
+			}
+		}
+
+		return a.ID < b.ID
+	})
+
+	return paginate(fruits, filter.Limit, filter.Offset), nil
+}
+
+func (s *Store) UpdateFruit(id int, update models.UpdateFruitRequest) (models.Fruit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fruit, ok := s.fruits[id]
+	if !ok {
+		return models.Fruit{}, models.ErrFruitNotFound
+	}
+
+	if fruit.ExpirationTime > models.MaxExpirationTime-update.ExtendExpirationSeconds {
+		return models.Fruit{}, models.ErrExpirationTooFar
+	}
+
+	if update.Name != nil {
+		fruit.Name = *update.Name
+	}
+	if update.Price != nil {
+		fruit.Price = *update.Price
+	}
+	fruit.ExpirationTime += update.ExtendExpirationSeconds
+	s.fruits[id] = fruit
+
+	return cloneFruit(fruit), nil
+}
+
+func (s *Store) GetFruitsInBucket(bucketID int) ([]models.Fruit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.fruitsInBucket(bucketID), nil
+}
+
+func (s *Store) AddFruitToBucket(fruitID, bucketID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.newBatch()
+	fruit, err := b.check(fruitID, bucketID, isLoose)
+	if err != nil {
+		return err
+	}
+
+	b.move(fruit, bucketID)
+	b.commit()
+
+	return nil
+}
+
+func (s *Store) AddFruitsToBucket(bucketID int, fruitIDs []int, allOrNothing bool) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[bucketID]; !ok {
+		return nil, models.ErrBucketNotFound
+	}
+
+	results := make([]error, len(fruitIDs))
+	b := s.newBatch()
+
+	for i, fruitID := range fruitIDs {
+		fruit, err := b.check(fruitID, bucketID, isLoose)
+		if err != nil {
+			results[i] = err
+			continue
+		}
+
+		b.move(fruit, bucketID)
+	}
+
+	if allOrNothing {
+		failed := false
+		for _, err := range results {
+			if err != nil {
+				failed = true
+				break
+			}
+		}
+
+		if failed {
+			for i, err := range results {
+				if err == nil {
+					results[i] = models.ErrBatchAborted
+				}
+			}
+			return results, nil
+		}
+	}
+
+	b.commit()
+
+	return results, nil
+}
+
+func (s *Store) MoveFruit(fruitID, fromBucketID, toBucketID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.newBatch()
+	fruit, err := b.checkMove(fruitID, fromBucketID, toBucketID)
+	if err != nil {
+		return err
+	}
+
+	b.move(fruit, toBucketID)
+	b.commit()
+
+	return nil
+}
+
+func (s *Store) ApplyMoves(moves []models.FruitMove) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.newBatch()
+	for i, m := range moves {
+		fruit, err := b.checkMove(m.FruitID, m.FromBucketID, m.ToBucketID)
+		if err != nil {
+			return fmt.Errorf("movimento %d: %w", i, err)
+		}
+
+		b.move(fruit, m.ToBucketID)
+	}
+
+	b.commit()
+
+	return nil
+}
+
+func (s *Store) RemoveFruitFromBucket(fruitID, bucketID int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fruit, ok := s.fruits[fruitID]
+	if !ok || !fruit.BucketID.Valid || int(fruit.BucketID.Int64) != bucketID {
+		return 0, nil
+	}
+
+	fruit.BucketID = sql.NullInt64{}
+	s.fruits[fruitID] = fruit
+
+	return 1, nil
+}
+
+func (s *Store) DeleteFruit(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.fruits, id)
+
+	return nil
+}
+
+func (s *Store) ExpireFruits(now int64) ([]models.ExpiredFruit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var archived []models.ExpiredFruit
+	for id, f := range s.fruits {
+		if f.ExpirationTime <= now {
+			archived = append(archived, models.ExpiredFruit{Fruit: f, ExpiredAt: now})
+			delete(s.fruits, id)
+		}
+	}
+
+	sort.Slice(archived, func(i, j int) bool {
+		if archived[i].ExpirationTime != archived[j].ExpirationTime {
+			return archived[i].ExpirationTime < archived[j].ExpirationTime
+		}
+		return archived[i].ID < archived[j].ID
+	})
+	s.expired = append(s.expired, archived...)
+
+	for i := range archived {
+		archived[i].Fruit = cloneFruit(archived[i].Fruit)
+	}
+
+	return archived, nil
+}
+
+func (s *Store) ListExpiredFruits(from, to int64) ([]models.ExpiredFruit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var fruits []models.ExpiredFruit
+	for _, f := range s.expired {
+		if (from != 0 && f.ExpirationTime < from) || (to != 0 && f.ExpirationTime > to) {
+			continue
+		}
+
+		f.Fruit = cloneFruit(f.Fruit)
+		fruits = append(fruits, f)
+	}
+
+	sort.Slice(fruits, func(i, j int) bool {
+		if fruits[i].ExpirationTime != fruits[j].ExpirationTime {
+			return fruits[i].ExpirationTime < fruits[j].ExpirationTime
+		}
+		return fruits[i].ID < fruits[j].ID
+	})
+
+	return fruits, nil
+}
+
+// matchesFruitFilter aplica os filtros da listagem a uma fruta, com a mesma
+// semântica das cláusulas WHERE do store SQLite.
+func matchesFruitFilter(f models.Fruit, filter models.FruitFilter) bool {
+	if filter.InBucket != nil && f.BucketID.Valid != *filter.InBucket {
+		return false
+	}
+	if filter.BucketID != 0 && (!f.BucketID.Valid || int(f.BucketID.Int64) != filter.BucketID) {
+		return false
+	}
+	if filter.TypeID != 0 && (f.TypeID == nil || *f.TypeID != filter.TypeID) {
+		return false
+	}
+	if filter.Name != "" && !strings.Contains(asciiLower(f.Name), asciiLower(filter.Name)) {
+		return false
+	}
+	if filter.Currency != "" && f.Price.Currency != filter.Currency {
+		return false
+	}
+	if filter.MinPrice != 0 && f.Price.Amount < filter.MinPrice {
+		return false
+	}
+	if filter.MaxPrice != 0 && f.Price.Amount > filter.MaxPrice {
+		return false
+	}
+	if filter.ExpiresBefore != 0 && f.ExpirationTime >= filter.ExpiresBefore {
+		return false
+	}
+	if filter.ExpiresAfter != 0 && f.ExpirationTime <= filter.ExpiresAfter {
+		return false
+	}
+
+	return true
+}
+
+// asciiLower converte apenas as letras ASCII para minúsculas, como o LIKE do
+// SQLite faz ao comparar textos.
+func asciiLower(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + ('a' - 'A')
+		}
+		return r
+	}, s)
+}
+
+// paginate aplica offset e limit a uma listagem já ordenada. Limit igual a
+// zero devolve todos os itens a partir do offset.
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+
+	return items
+}
+
+// fruitsInBucket retorna cópias das frutas do balde ordenadas por ID. Deve
+// ser chamada com o mutex já adquirido.
+func (s *Store) fruitsInBucket(bucketID int) []models.Fruit {
+	var fruits []models.Fruit
+	for _, f := range s.fruits {
+		if f.BucketID.Valid && int(f.BucketID.Int64) == bucketID {
+			fruits = append(fruits, cloneFruit(f))
+		}
+	}
+
+	sort.Slice(fruits, func(i, j int) bool {
+		return fruits[i].ID < fruits[j].ID
+	})
+
+	return fruits
+}
+
+func (s *Store) CreateFruitType(t *models.FruitType) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fruitTypeNameTaken(t.Name, 0) {
+		return models.ErrFruitTypeNameTaken
+	}
+
+	t.ID = s.nextTypeID
+	s.nextTypeID++
+	s.types[t.ID] = *t
+
+	return nil
+}
+
+func (s *Store) GetFruitType(id int) (models.FruitType, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.types[id]
+	if !ok {
+		return models.FruitType{}, models.ErrFruitTypeNotFound
+	}
+
+	return t, nil
+}
+
+func (s *Store) ListFruitTypes() ([]models.FruitType, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var types []models.FruitType
+	for _, t := range s.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].ID < types[j].ID })
+
+	return types, nil
+}
+
+func (s *Store) UpdateFruitType(id int, update models.UpdateFruitTypeRequest) (models.FruitType, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.types[id]
+	if !ok {
+		return models.FruitType{}, models.ErrFruitTypeNotFound
+	}
+
+	t := update.Apply(current)
+	if s.fruitTypeNameTaken(t.Name, id) {
+		return models.FruitType{}, models.ErrFruitTypeNameTaken
+	}
+	s.types[id] = t
+
+	return t, nil
+}
+
+func (s *Store) DeleteFruitType(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.types[id]; !ok {
+		return models.ErrFruitTypeNotFound
+	}
+
+	for _, f := range s.fruits {
+		if f.TypeID != nil && *f.TypeID == id {
+			return models.ErrFruitTypeInUse
+		}
+	}
+
+	delete(s.types, id)
+
+	return nil
+}
+
+// fruitTypeNameTaken indica se outro tipo que não o exceptID já usa o nome,
+// ignorando maiúsculas como o COLLATE NOCASE do SQLite.
+func (s *Store) fruitTypeNameTaken(name string, exceptID int) bool {
+	for id, t := range s.types {
+		if id != exceptID && asciiLower(t.Name) == asciiLower(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// batch acumula alterações sobre o estado do store, para que as operações
+// possam verificar vários depósitos em sequência e só publicá-los no fim.
+// Quem usa deve segurar o lock de escrita.
+type batch struct {
+	s      *Store
+	fruits map[int]models.Fruit
+	loads  map[int]models.BucketLoad
+}
+
+func (s *Store) newBatch() *batch {
+	return &batch{s: s, fruits: make(map[int]models.Fruit), loads: make(map[int]models.BucketLoad)}
+}
+
+func (b *batch) fruit(id int) (models.Fruit, bool) {
+	if f, ok := b.fruits[id]; ok {
+		return f, true
+	}
+
+	f, ok := b.s.fruits[id]
+	return f, ok
+}
+
+func (b *batch) load(bucketID int) models.BucketLoad {
+	if load, ok := b.loads[bucketID]; ok {
+		return load
+	}
+
+	var load models.BucketLoad
+	for _, f := range b.s.fruitsInBucket(bucketID) {
+		load.Add(f)
+	}
+	b.loads[bucketID] = load
+
+	return load
+}
+
+// check verifica se a fruta pode entrar no balde, na mesma ordem usada pelo
+// store SQLite: existência do balde, quantidade, existência da fruta,
+// situação atual dela (state) e, por fim, peso e volume.
+func (b *batch) check(fruitID, bucketID int, state func(models.Fruit) error) (models.Fruit, error) {
+	bucket, ok := b.s.buckets[bucketID]
+	if !ok {
+		return models.Fruit{}, models.ErrBucketNotFound
+	}
+
+	load := b.load(bucketID)
+	if load.Count >= bucket.Capacity {
+		return models.Fruit{}, models.ErrBucketFull
+	}
+
+	fruit, ok := b.fruit(fruitID)
+	if !ok {
+		return models.Fruit{}, models.ErrFruitNotFound
+	}
+
+	if err := state(fruit); err != nil {
+		return models.Fruit{}, err
+	}
+
+	return fruit, bucket.CheckFit(load, fruit)
+}
+
+// checkMove verifica uma transferência como o store SQLite: a fruta precisa
+// existir e estar na origem antes que o destino seja verificado.
+func (b *batch) checkMove(fruitID, fromBucketID, toBucketID int) (models.Fruit, error) {
+	fruit, ok := b.fruit(fruitID)
+	if !ok {
+		return models.Fruit{}, models.ErrFruitNotFound
+	}
+
+	if err := isIn(fromBucketID)(fruit); err != nil {
+		return models.Fruit{}, err
+	}
+
+	return b.check(fruitID, toBucketID, isIn(fromBucketID))
+}
+
+// move coloca a fruta no balde, atualizando a carga da origem e do destino.
+func (b *batch) move(fruit models.Fruit, bucketID int) {
+	if fruit.BucketID.Valid {
+		from := b.load(int(fruit.BucketID.Int64))
+		from.Remove(fruit)
+		b.loads[int(fruit.BucketID.Int64)] = from
+	}
+
+	to := b.load(bucketID)
+	to.Add(fruit)
+	b.loads[bucketID] = to
+
+	fruit.BucketID = sql.NullInt64{Int64: int64(bucketID), Valid: true}
+	b.fruits[fruit.ID] = fruit
+}
+
+// commit publica as alterações no store.
+func (b *batch) commit() {
+	for id, fruit := range b.fruits {
+		b.s.fruits[id] = fruit
+	}
+}
+
+// isLoose exige que a fruta esteja solta, para depósitos.
+func isLoose(f models.Fruit) error {
+	if f.BucketID.Valid {
+		return models.ErrFruitInBucket
+	}
+
+	return nil
+}
+
+// isIn exige que a fruta esteja no balde informado, para transferências.
+func isIn(bucketID int) func(models.Fruit) error {
+	return func(f models.Fruit) error {
+		if !f.BucketID.Valid || int(f.BucketID.Int64) != bucketID {
+			return models.ErrFruitNotInBucket
+		}
+
+		return nil
+	}
+}
+
+func (s *Store) CreateWebhook(w *models.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.ID = s.nextWebhookID
+	s.nextWebhookID++
+	s.webhooks[w.ID] = cloneWebhook(*w)
+
+	return nil
+}
+
+func (s *Store) GetWebhook(id int) (models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	w, ok := s.webhooks[id]
+	if !ok {
+		return models.Webhook{}, models.ErrWebhookNotFound
+	}
+
+	return cloneWebhook(w), nil
+}
+
+func (s *Store) ListWebhooks() ([]models.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var webhooks []models.Webhook
+	for _, w := range s.webhooks {
+		webhooks = append(webhooks, cloneWebhook(w))
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+
+	return webhooks, nil
+}
+
+// DeleteWebhook exclui o webhook e as entregas dele, como o ON DELETE
+// CASCADE do SQLite.
+func (s *Store) DeleteWebhook(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return models.ErrWebhookNotFound
+	}
+
+	delete(s.webhooks, id)
+	for deliveryID, d := range s.deliveries {
+		if d.WebhookID == id {
+			delete(s.deliveries, deliveryID)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) CreateDelivery(d *models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[d.WebhookID]; !ok {
+		return models.ErrWebhookNotFound
+	}
+
+	d.ID = s.nextDeliveryID
+	s.nextDeliveryID++
+	s.deliveries[d.ID] = cloneDelivery(*d)
+
+	return nil
+}
+
+func (s *Store) UpdateDelivery(d models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.deliveries[d.ID]
+	if !ok {
+		return models.ErrDeliveryNotFound
+	}
+
+	current.Status = d.Status
+	current.Attempts = d.Attempts
+	current.LastStatusCode = clonePtr(d.LastStatusCode)
+	current.LastError = d.LastError
+	current.UpdatedAt = d.UpdatedAt
+	s.deliveries[d.ID] = current
+
+	return nil
+}
+
+func (s *Store) ListDeliveries(webhookID int) ([]models.WebhookDelivery, error) {
+	deliveries := s.filterDeliveries(func(d models.WebhookDelivery) bool { return d.WebhookID == webhookID })
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+
+	return deliveries, nil
+}
+
+func (s *Store) ListPendingDeliveries() ([]models.WebhookDelivery, error) {
+	deliveries := s.filterDeliveries(func(d models.WebhookDelivery) bool { return d.Status == models.DeliveryPending })
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+
+	return deliveries, nil
+}
+
+// filterDeliveries devolve as entregas que satisfazem keep, sem ordem definida.
+func (s *Store) filterDeliveries(keep func(models.WebhookDelivery) bool) []models.WebhookDelivery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, d := range s.deliveries {
+		if keep(d) {
+			deliveries = append(deliveries, cloneDelivery(d))
+		}
+	}
+
+	return deliveries
+}
+
+// clonePtr copia o valor apontado, para que o store não compartilhe
+// ponteiros com quem chama.
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+
+	v := *p
+	return &v
+}
+
+func cloneBucket(b models.Bucket) models.Bucket {
+	b.MaxWeight = clonePtr(b.MaxWeight)
+	b.MaxVolume = clonePtr(b.MaxVolume)
+	b.ExpiryWarningSeconds = clonePtr(b.ExpiryWarningSeconds)
+
+	return b
+}
+
+func cloneFruit(f models.Fruit) models.Fruit {
+	f.Weight = clonePtr(f.Weight)
+	f.Volume = clonePtr(f.Volume)
+	f.TypeID = clonePtr(f.TypeID)
+
+	return f
+}
+
+func cloneWebhook(w models.Webhook) models.Webhook {
+	w.Events = slices.Clone(w.Events)
+
+	return w
+}
+
+func cloneDelivery(d models.WebhookDelivery) models.WebhookDelivery {
+	d.Payload = slices.Clone(d.Payload)
+	d.LastStatusCode = clonePtr(d.LastStatusCode)
+
+	return d
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/mr-utzig/planne-test/database/storetest"
+)
+
+// TestMemoryStoreConformance executa a suíte compartilhada contra o store em memória.
+func TestMemoryStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Store {
+		return New()
+	})
+}
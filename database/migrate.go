@@ -0,0 +1,213 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration é uma alteração versionada do schema, com os scripts de
+// aplicação (up) e reversão (down).
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus descreve se uma migração já foi aplicada ao banco.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrations carrega as migrações embutidas, ordenadas por versão. Os
+// arquivos seguem o padrão NNNN_nome.up.sql / NNNN_nome.down.sql.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		prefix, title, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("nome de migração inválido: %s", name)
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("versão de migração inválida: %s", name)
+		}
+
+		content, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migração %04d sem script up ou down", m.Version)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// MigrateUp aplica todas as migrações pendentes, cada uma em sua própria
+// transação, e devolve as que foram aplicadas.
+func MigrateUp(db *sql.DB) ([]Migration, error) {
+	states, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, state := range states {
+		if state.Applied {
+			continue
+		}
+
+		err := inTx(db, func(tx *sql.Tx) error {
+			if _, err := tx.Exec(state.Up); err != nil {
+				return err
+			}
+
+			_, err := tx.Exec(
+				"INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)",
+				state.Version, time.Now().Unix(),
+			)
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migração %04d_%s: %w", state.Version, state.Name, err)
+		}
+
+		applied = append(applied, state.Migration)
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverte as últimas `steps` migrações aplicadas, da mais nova
+// para a mais antiga, e devolve as que foram revertidas.
+func MigrateDown(db *sql.DB, steps int) ([]Migration, error) {
+	states, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+		state := states[i]
+		if !state.Applied {
+			continue
+		}
+
+		err := inTx(db, func(tx *sql.Tx) error {
+			if _, err := tx.Exec(state.Down); err != nil {
+				return err
+			}
+
+			_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", state.Version)
+			return err
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("migração %04d_%s: %w", state.Version, state.Name, err)
+		}
+
+		reverted = append(reverted, state.Migration)
+	}
+
+	return reverted, nil
+}
+
+// Status lista todas as migrações conhecidas, indicando quais já foram aplicadas.
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	if _, err := db.Exec(`
+    CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        applied_at INTEGER NOT NULL
+    )`); err != nil {
+		return nil, err
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]int64)
+	for rows.Next() {
+		var version int
+		var at int64
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		states[i] = MigrationStatus{Migration: m}
+		if at, ok := appliedAt[m.Version]; ok {
+			states[i].Applied = true
+			states[i].AppliedAt = time.Unix(at, 0)
+		}
+	}
+
+	return states, nil
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestMigrateUpAndDown verifica que as migrações são registradas em
+// schema_migrations e podem ser revertidas e reaplicadas.
+func TestMigrateUpAndDown(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatalf("Expected embedded migrations")
+	}
+
+	applied, err := MigrateUp(db)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("Expected %d migrations applied. Got %d", len(migrations), len(applied))
+	}
+
+	// Uma segunda execução não deve ter nada a aplicar
+	applied, err = MigrateUp(db)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("Expected no pending migrations. Got %d, %v", len(applied), err)
+	}
+
+	states, err := Status(db)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, state := range states {
+		if !state.Applied {
+			t.Errorf("Expected migration %d to be applied", state.Version)
+		}
+	}
+
+	reverted, err := MigrateDown(db, len(migrations))
+	if err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if len(reverted) != len(migrations) {
+		t.Errorf("Expected %d migrations reverted. Got %d", len(migrations), len(reverted))
+	}
+
+	var tables int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('buckets', 'fruits')").Scan(&tables)
+	if tables != 0 {
+		t.Errorf("Expected tables to be dropped. Got %d", tables)
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("MigrateUp after down: %v", err)
+	}
+}
+
+// TestMigrateLegacyDatabase verifica que um banco criado antes do controle de
+// versões recebe as migrações sem perder dados.
+func TestMigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	migrations, _ := Migrations()
+	if _, err := db.Exec(migrations[0].Up); err != nil {
+		t.Fatalf("creating legacy schema: %v", err)
+	}
+	db.Exec("INSERT INTO buckets (capacity) VALUES (3)")
+	db.Close()
+
+	db, err = InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM buckets").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected legacy bucket to be kept. Got %d buckets", count)
+	}
+}
+
+// TestMigrateMoneyColumns verifica que os preços decimais antigos viram
+// centavos de real sem erro de arredondamento.
+func TestMigrateMoneyColumns(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	// Desfaz a migração dos preços e as que vieram depois dela.
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations: %v", err)
+	}
+	steps := 0
+	for _, m := range migrations {
+		if m.Version >= 6 {
+			steps++
+		}
+	}
+	if _, err := MigrateDown(db, steps); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+
+	db.Exec("INSERT INTO fruits (name, price, expiration_time) VALUES ('Apple', 1.15, 100)")
+	db.Exec("INSERT INTO fruit_types (name, shelf_life_seconds, default_price) VALUES ('Banana', 60, 0.29)")
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	var price, defaultPrice int64
+	var currency string
+	db.QueryRow("SELECT price_minor, currency FROM fruits").Scan(&price, &currency)
+	db.QueryRow("SELECT default_price_minor FROM fruit_types").Scan(&defaultPrice)
+	if price != 115 || currency != "BRL" || defaultPrice != 29 {
+		t.Errorf("Expected 115 BRL and 29. Got %d %s and %d", price, currency, defaultPrice)
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS fruits;
+DROP TABLE IF EXISTS buckets;
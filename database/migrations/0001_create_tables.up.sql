@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS buckets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    capacity INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS fruits (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    price REAL NOT NULL,
+    expiration_time INTEGER NOT NULL,
+    bucket_id INTEGER,
+    FOREIGN KEY(bucket_id) REFERENCES buckets(id) ON DELETE SET NULL
+);
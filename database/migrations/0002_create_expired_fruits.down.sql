@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_expired_fruits_expiration_time;
+DROP TABLE IF EXISTS expired_fruits;
@@ -0,0 +1,10 @@
+CREATE TABLE expired_fruits (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    price REAL NOT NULL,
+    expiration_time INTEGER NOT NULL,
+    bucket_id INTEGER,
+    expired_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_expired_fruits_expiration_time ON expired_fruits (expiration_time);
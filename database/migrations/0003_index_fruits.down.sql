@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_fruits_name;
+DROP INDEX IF EXISTS idx_fruits_price;
+DROP INDEX IF EXISTS idx_fruits_expiration_time;
+DROP INDEX IF EXISTS idx_fruits_bucket_id;
@@ -0,0 +1,4 @@
+CREATE INDEX idx_fruits_bucket_id ON fruits (bucket_id);
+CREATE INDEX idx_fruits_expiration_time ON fruits (expiration_time);
+CREATE INDEX idx_fruits_price ON fruits (price);
+CREATE INDEX idx_fruits_name ON fruits (name);
@@ -0,0 +1,6 @@
+ALTER TABLE buckets DROP COLUMN max_volume;
+ALTER TABLE buckets DROP COLUMN max_weight;
+ALTER TABLE expired_fruits DROP COLUMN volume;
+ALTER TABLE expired_fruits DROP COLUMN weight;
+ALTER TABLE fruits DROP COLUMN volume;
+ALTER TABLE fruits DROP COLUMN weight;
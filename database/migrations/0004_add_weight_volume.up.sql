@@ -0,0 +1,6 @@
+ALTER TABLE fruits ADD COLUMN weight REAL;
+ALTER TABLE fruits ADD COLUMN volume REAL;
+ALTER TABLE expired_fruits ADD COLUMN weight REAL;
+ALTER TABLE expired_fruits ADD COLUMN volume REAL;
+ALTER TABLE buckets ADD COLUMN max_weight REAL;
+ALTER TABLE buckets ADD COLUMN max_volume REAL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_fruits_type_id;
+ALTER TABLE expired_fruits DROP COLUMN type_id;
+ALTER TABLE fruits DROP COLUMN type_id;
+DROP TABLE IF EXISTS fruit_types;
@@ -0,0 +1,13 @@
+CREATE TABLE fruit_types (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    shelf_life_seconds INTEGER NOT NULL,
+    default_price REAL NOT NULL
+);
+
+-- Sem chave estrangeira para que a coluna possa ser removida no down; o
+-- store recusa a exclusão de tipos que ainda têm frutas.
+ALTER TABLE fruits ADD COLUMN type_id INTEGER;
+ALTER TABLE expired_fruits ADD COLUMN type_id INTEGER;
+
+CREATE INDEX idx_fruits_type_id ON fruits (type_id);
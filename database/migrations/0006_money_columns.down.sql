@@ -0,0 +1,17 @@
+-- A moeda se perde na reversão; as quantias voltam como decimais de 2 casas.
+ALTER TABLE fruit_types ADD COLUMN default_price REAL NOT NULL DEFAULT 0;
+UPDATE fruit_types SET default_price = default_price_minor / 100.0;
+ALTER TABLE fruit_types DROP COLUMN currency;
+ALTER TABLE fruit_types DROP COLUMN default_price_minor;
+
+ALTER TABLE expired_fruits ADD COLUMN price REAL NOT NULL DEFAULT 0;
+UPDATE expired_fruits SET price = price_minor / 100.0;
+ALTER TABLE expired_fruits DROP COLUMN currency;
+ALTER TABLE expired_fruits DROP COLUMN price_minor;
+
+ALTER TABLE fruits ADD COLUMN price REAL NOT NULL DEFAULT 0;
+UPDATE fruits SET price = price_minor / 100.0;
+DROP INDEX IF EXISTS idx_fruits_currency_price;
+ALTER TABLE fruits DROP COLUMN currency;
+ALTER TABLE fruits DROP COLUMN price_minor;
+CREATE INDEX idx_fruits_price ON fruits (price);
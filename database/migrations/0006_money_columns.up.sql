@@ -0,0 +1,18 @@
+-- Os preços passam a ser inteiros em unidades menores da moeda. Os valores
+-- existentes não tinham moeda e são convertidos como reais (2 casas).
+ALTER TABLE fruits ADD COLUMN price_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE fruits ADD COLUMN currency TEXT NOT NULL DEFAULT 'BRL';
+UPDATE fruits SET price_minor = CAST(ROUND(price * 100) AS INTEGER);
+DROP INDEX IF EXISTS idx_fruits_price;
+ALTER TABLE fruits DROP COLUMN price;
+CREATE INDEX idx_fruits_currency_price ON fruits (currency, price_minor);
+
+ALTER TABLE expired_fruits ADD COLUMN price_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE expired_fruits ADD COLUMN currency TEXT NOT NULL DEFAULT 'BRL';
+UPDATE expired_fruits SET price_minor = CAST(ROUND(price * 100) AS INTEGER);
+ALTER TABLE expired_fruits DROP COLUMN price;
+
+ALTER TABLE fruit_types ADD COLUMN default_price_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE fruit_types ADD COLUMN currency TEXT NOT NULL DEFAULT 'BRL';
+UPDATE fruit_types SET default_price_minor = CAST(ROUND(default_price * 100) AS INTEGER);
+ALTER TABLE fruit_types DROP COLUMN default_price;
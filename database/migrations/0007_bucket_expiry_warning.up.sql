@@ -0,0 +1 @@
+ALTER TABLE buckets ADD COLUMN expiry_warning_seconds INTEGER;
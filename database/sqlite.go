@@ -0,0 +1,1205 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// errBatchRollback desfaz a transação de um lote que teve alguma falha; não
+// chega a ser devolvido para quem chamou o store.
+var errBatchRollback = errors.New("lote desfeito")
+
+// SQLiteStore implementa models.BucketStore, models.FruitStore,
+// models.FruitTypeStore e models.WebhookStore sobre um banco SQLite.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore cria um store a partir de uma conexão já inicializada.
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+func (s *SQLiteStore) CreateBucket(b *models.Bucket) error {
+	result, err := s.db.Exec(
+		"INSERT INTO buckets (capacity, max_weight, max_volume, expiry_warning_seconds) VALUES (?, ?, ?, ?)",
+		b.Capacity, b.MaxWeight, b.MaxVolume, b.ExpiryWarningSeconds,
+	)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	id, _ := result.LastInsertId()
+	b.ID = int(id)
+
+	return nil
+}
+
+func (s *SQLiteStore) GetBucket(id int) (models.Bucket, error) {
+	var b models.Bucket
+	row := s.db.QueryRow("SELECT id, capacity, max_weight, max_volume, expiry_warning_seconds FROM buckets WHERE id = ?", id)
+
+	if err := row.Scan(&b.ID, &b.Capacity, &b.MaxWeight, &b.MaxVolume, &b.ExpiryWarningSeconds); err != nil {
+		if err == sql.ErrNoRows {
+			return b, models.ErrBucketNotFound
+		}
+
+		log.Println(err)
+		return b, err
+	}
+
+	return b, nil
+}
+
+func (s *SQLiteStore) ListBuckets() ([]models.Bucket, error) {
+	rows, err := s.db.Query("SELECT id, capacity, max_weight, max_volume, expiry_warning_seconds FROM buckets ORDER BY id")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []models.Bucket
+	for rows.Next() {
+		var bucket models.Bucket
+		if err := rows.Scan(&bucket.ID, &bucket.Capacity, &bucket.MaxWeight, &bucket.MaxVolume, &bucket.ExpiryWarningSeconds); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+// bucketSortColumns mapeia os campos de ordenação para as colunas agregadas.
+var bucketSortColumns = map[string]string{
+	models.BucketSortOccupancy:  "occupancy",
+	models.BucketSortTotalValue: "total_value",
+	models.BucketSortCapacity:   "capacity",
+	models.BucketSortID:         "id",
+}
+
+// ListBucketDetails monta a listagem com uma única consulta: os agregados são
+// calculados por GROUP BY, filtros, ordenação e paginação são feitos pelo
+// banco e as frutas da página vêm no mesmo resultado via LEFT JOIN, agrupadas
+// aqui por balde.
+func (s *SQLiteStore) ListBucketDetails(filter models.BucketFilter) ([]models.BucketDetails, error) {
+	column, ok := bucketSortColumns[filter.SortBy]
+	if !ok {
+		column = "occupancy"
+	}
+	direction, comparison := "ASC", ">"
+	if filter.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	where := "1 = 1"
+	var args []any
+	if filter.MinOccupancy != 0 {
+		where += " AND occupancy >= ?"
+		args = append(args, filter.MinOccupancy)
+	}
+	if filter.Empty != nil {
+		if *filter.Empty {
+			where += " AND fruit_count = 0"
+		} else {
+			where += " AND fruit_count > 0"
+		}
+	}
+	if filter.Full != nil {
+		if *filter.Full {
+			where += " AND fruit_count >= capacity"
+		} else {
+			where += " AND fruit_count < capacity"
+		}
+	}
+	if filter.After != nil {
+		where += " AND (" + column + ", id) " + comparison + " (?, ?)"
+		args = append(args, filter.After.Value, filter.After.ID)
+	}
+
+	limit := ""
+	if filter.Limit > 0 {
+		limit = "LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	order := column + " " + direction + ", id " + direction
+	rows, err := s.db.Query(`
+		WITH details AS (
+			SELECT b.id, b.capacity, b.max_weight, b.max_volume, b.expiry_warning_seconds,
+			       COUNT(f.id) AS fruit_count,
+			       COALESCE(SUM(CASE WHEN f.currency = ? THEN f.price_minor END), 0) AS total_value,
+			       (CAST(COUNT(f.id) AS REAL) / b.capacity) * 100 AS occupancy
+			FROM buckets b
+			LEFT JOIN fruits f ON f.bucket_id = b.id
+			GROUP BY b.id
+		), page AS (
+			SELECT * FROM details WHERE `+where+` ORDER BY `+order+` `+limit+`
+		)
+		SELECT page.id, page.capacity, page.max_weight, page.max_volume, page.expiry_warning_seconds,
+		       page.fruit_count, page.occupancy,
+		       f.id, f.name, f.price_minor, f.currency, f.expiration_time, f.weight, f.volume, f.type_id
+		FROM page
+		LEFT JOIN fruits f ON f.bucket_id = page.id
+		ORDER BY page.`+column+` `+direction+`, page.id `+direction+`, f.id`,
+		append([]any{filter.SortCurrency()}, args...)...,
+	)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var details []models.BucketDetails
+	for rows.Next() {
+		var d models.BucketDetails
+		var fruitID, fruitPrice, fruitExpiration sql.NullInt64
+		var fruitName, fruitCurrency sql.NullString
+		var fruitWeight, fruitVolume *float64
+		var fruitType *int
+
+		if err := rows.Scan(
+			&d.ID, &d.Capacity, &d.MaxWeight, &d.MaxVolume, &d.ExpiryWarningSeconds,
+			&d.FruitCount, &d.Occupancy,
+			&fruitID, &fruitName, &fruitPrice, &fruitCurrency, &fruitExpiration, &fruitWeight, &fruitVolume, &fruitType,
+		); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		if len(details) == 0 || details[len(details)-1].ID != d.ID {
+			details = append(details, d)
+		}
+
+		if fruitID.Valid {
+			last := &details[len(details)-1]
+			last.Fruits = append(last.Fruits, models.Fruit{
+				ID:             int(fruitID.Int64),
+				Name:           fruitName.String,
+				Price:          models.Money{Amount: fruitPrice.Int64, Currency: fruitCurrency.String},
+				ExpirationTime: fruitExpiration.Int64,
+				BucketID:       sql.NullInt64{Int64: int64(d.ID), Valid: true},
+				Weight:         fruitWeight,
+				Volume:         fruitVolume,
+				TypeID:         fruitType,
+			})
+		}
+	}
+
+	for i := range details {
+		details[i].CalcTotalValue()
+		details[i].CalcDimensions()
+	}
+
+	return details, rows.Err()
+}
+
+// UpdateBucket altera o balde com um único UPDATE condicional, para que
+// nenhum depósito concorrente passe entre a contagem e a escrita e para que
+// as alterações sejam aplicadas juntas.
+func (s *SQLiteStore) UpdateBucket(id int, update models.UpdateBucketRequest) (models.Bucket, error) {
+	result, err := s.db.Exec(`
+		UPDATE buckets
+		SET capacity = COALESCE(?, capacity),
+		    expiry_warning_seconds = CASE WHEN ? IS NULL THEN expiry_warning_seconds ELSE NULLIF(?, 0) END
+		WHERE id = ? AND (? IS NULL OR (SELECT COUNT(*) FROM fruits WHERE bucket_id = ?) <= ?)`,
+		update.Capacity, update.ExpiryWarningSeconds, update.ExpiryWarningSeconds,
+		id, update.Capacity, id, update.Capacity,
+	)
+	if err != nil {
+		log.Println(err)
+		return models.Bucket{}, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.Bucket{}, err
+	}
+
+	if rowsAffected == 0 {
+		if _, err := s.GetBucket(id); err != nil {
+			return models.Bucket{}, err
+		}
+
+		return models.Bucket{}, models.ErrCapacityBelowCount
+	}
+
+	return s.GetBucket(id)
+}
+
+func (s *SQLiteStore) DeleteBucket(id int) error {
+	_, err := s.db.Exec("DELETE FROM buckets WHERE id = ?", id)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) CreateFruit(f *models.Fruit) error {
+	if f.BucketID.Valid {
+		if _, err := s.GetBucket(int(f.BucketID.Int64)); err != nil {
+			return err
+		}
+	}
+
+	result, err := s.db.Exec(
+		"INSERT INTO fruits (name, price_minor, currency, expiration_time, bucket_id, weight, volume, type_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		f.Name, f.Price.Amount, f.Price.Currency, f.ExpirationTime, f.BucketID, f.Weight, f.Volume, f.TypeID,
+	)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	id, _ := result.LastInsertId()
+	f.ID = int(id)
+
+	return nil
+}
+
+// CreateFruits insere o lote dentro de uma transação. As frutas com balde
+// contam para a capacidade das seguintes, já que a carga é lida na mesma
+// transação.
+func (s *SQLiteStore) CreateFruits(fruits []models.Fruit) ([]error, error) {
+	results := make([]error, len(fruits))
+	ids := make([]int, len(fruits))
+
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		failed := false
+		for i, f := range fruits {
+			if f.BucketID.Valid {
+				bucket, load, err := bucketLoad(tx, int(f.BucketID.Int64))
+				if err == nil {
+					err = bucket.CheckFit(load, f)
+				}
+				if isRuleError(err) {
+					results[i], failed = err, true
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+			// Mesmo depois de uma falha as frutas seguintes são inseridas, para
+			// que todos os erros apareçam; o rollback descarta tudo no fim.
+			result, err := tx.Exec(
+				"INSERT INTO fruits (name, price_minor, currency, expiration_time, bucket_id, weight, volume, type_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				f.Name, f.Price.Amount, f.Price.Currency, f.ExpirationTime, f.BucketID, f.Weight, f.Volume, f.TypeID,
+			)
+			if err != nil {
+				return err
+			}
+
+			id, _ := result.LastInsertId()
+			ids[i] = int(id)
+		}
+
+		if failed {
+			return errBatchRollback
+		}
+
+		return nil
+	})
+	if err == errBatchRollback {
+		return results, nil
+	}
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	for i := range fruits {
+		fruits[i].ID = ids[i]
+	}
+
+	return results, nil
+}
+
+func (s *SQLiteStore) GetFruit(id int) (models.Fruit, error) {
+	f, err := getFruit(s.db, id)
+	if err != nil && err != models.ErrFruitNotFound {
+		log.Println(err)
+	}
+
+	return f, err
+}
+
+// fruitSortColumns mapeia os campos de ordenação para as colunas indexadas.
+// Os preços só são comparáveis na mesma moeda, então a ordenação por preço
+// agrupa as frutas por moeda, sempre em ordem alfabética.
+var fruitSortColumns = map[string]string{
+	models.FruitSortID:         "id",
+	models.FruitSortPrice:      "currency, price_minor",
+	models.FruitSortName:       "name",
+	models.FruitSortExpiration: "expiration_time",
+}
+
+func (s *SQLiteStore) ListFruits(filter models.FruitFilter) ([]models.Fruit, error) {
+	query := "SELECT " + fruitColumns + " FROM fruits WHERE 1 = 1"
+	var args []any
+
+	if filter.InBucket != nil {
+		if *filter.InBucket {
+			query += " AND bucket_id IS NOT NULL"
+		} else {
+			query += " AND bucket_id IS NULL"
+		}
+	}
+	if filter.BucketID != 0 {
+		query += " AND bucket_id = ?"
+		args = append(args, filter.BucketID)
+	}
+	if filter.TypeID != 0 {
+		query += " AND type_id = ?"
+		args = append(args, filter.TypeID)
+	}
+	if filter.Name != "" {
+		query += ` AND name LIKE ? ESCAPE '\'`
+		args = append(args, "%"+escapeLike(filter.Name)+"%")
+	}
+	if filter.Currency != "" {
+		query += " AND currency = ?"
+		args = append(args, filter.Currency)
+	}
+	if filter.MinPrice != 0 {
+		query += " AND price_minor >= ?"
+		args = append(args, filter.MinPrice)
+	}
+	if filter.MaxPrice != 0 {
+		query += " AND price_minor <= ?"
+		args = append(args, filter.MaxPrice)
+	}
+	if filter.ExpiresBefore != 0 {
+		query += " AND expiration_time < ?"
+		args = append(args, filter.ExpiresBefore)
+	}
+	if filter.ExpiresAfter != 0 {
+		query += " AND expiration_time > ?"
+		args = append(args, filter.ExpiresAfter)
+	}
+
+	column, ok := fruitSortColumns[filter.SortBy]
+	if !ok {
+		column = "id"
+	}
+	direction := "ASC"
+	if filter.Desc {
+		direction = "DESC"
+	}
+	query += " ORDER BY " + column + " " + direction + ", id " + direction
+
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	} else if filter.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFruits(rows)
+}
+
+func (s *SQLiteStore) UpdateFruit(id int, update models.UpdateFruitRequest) (models.Fruit, error) {
+	var amount, currency any
+	if update.Price != nil {
+		amount, currency = update.Price.Amount, update.Price.Currency
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE fruits
+		SET name = COALESCE(?, name),
+		    price_minor = COALESCE(?, price_minor),
+		    currency = COALESCE(?, currency),
+		    expiration_time = expiration_time + ?
+		WHERE id = ? AND expiration_time <= ? - ?`,
+		update.Name, amount, currency, update.ExtendExpirationSeconds, id, models.MaxExpirationTime, update.ExtendExpirationSeconds,
+	)
+	if err != nil {
+		log.Println(err)
+		return models.Fruit{}, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return models.Fruit{}, err
+	}
+
+	// Sem linhas alteradas, a fruta não existe ou a extensão passaria do
+	// limite; nesse caso nada é alterado.
+	if rowsAffected == 0 {
+		if _, err := getFruit(s.db, id); err != nil {
+			return models.Fruit{}, err
+		}
+		return models.Fruit{}, models.ErrExpirationTooFar
+	}
+
+	return s.GetFruit(id)
+}
+
+func (s *SQLiteStore) GetFruitsInBucket(bucketID int) ([]models.Fruit, error) {
+	rows, err := s.db.Query("SELECT "+fruitColumns+" FROM fruits WHERE bucket_id = ? ORDER BY id", bucketID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFruits(rows)
+}
+
+// AddFruitToBucket deposita a fruta com um único UPDATE condicional, de modo
+// que a verificação dos limites do balde e a escrita aconteçam atomicamente.
+// Se nenhuma linha for alterada, as leituras seguintes só servem para
+// descobrir qual regra impediu o depósito.
+func (s *SQLiteStore) AddFruitToBucket(fruitID, bucketID int) error {
+	result, err := s.db.Exec(`
+		UPDATE fruits SET bucket_id = ?
+		WHERE id = ?
+		  AND bucket_id IS NULL
+		  AND `+fitsInBucket,
+		bucketID, fruitID, bucketID,
+	)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	if err := checkDeposit(s.db, fruitID, bucketID, isLoose); err != nil {
+		return err
+	}
+
+	// Uma vaga foi liberada entre o UPDATE e as leituras acima; no momento
+	// do depósito o balde estava cheio.
+	return models.ErrBucketFull
+}
+
+// AddFruitsToBucket deposita o lote dentro de uma transação. Como a
+// transação é aberta com lock de escrita (_txlock=immediate), a carga lida
+// a cada fruta continua válida até o commit.
+func (s *SQLiteStore) AddFruitsToBucket(bucketID int, fruitIDs []int, allOrNothing bool) ([]error, error) {
+	results := make([]error, len(fruitIDs))
+
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		if _, _, err := bucketLoad(tx, bucketID); err != nil {
+			return err
+		}
+
+		for i, fruitID := range fruitIDs {
+			err := checkDeposit(tx, fruitID, bucketID, isLoose)
+			if isRuleError(err) {
+				results[i] = err
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if _, err := tx.Exec("UPDATE fruits SET bucket_id = ? WHERE id = ?", bucketID, fruitID); err != nil {
+				return err
+			}
+		}
+
+		if allOrNothing && abortBatch(results) {
+			return errBatchRollback
+		}
+
+		return nil
+	})
+	if err != nil && err != errBatchRollback {
+		if !errors.Is(err, models.ErrNotFound) {
+			log.Println(err)
+		}
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// MoveFruit troca o balde da fruta com um único UPDATE condicional, então a
+// fruta nunca fica solta e o destino não pode encher no meio da operação.
+func (s *SQLiteStore) MoveFruit(fruitID, fromBucketID, toBucketID int) error {
+	result, err := s.db.Exec(`
+		UPDATE fruits SET bucket_id = ?
+		WHERE id = ?
+		  AND bucket_id = ?
+		  AND `+fitsInBucket,
+		toBucketID, fruitID, fromBucketID, toBucketID,
+	)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	if err := checkMove(s.db, fruitID, fromBucketID, toBucketID); err != nil {
+		return err
+	}
+
+	// Uma vaga foi liberada entre o UPDATE e as leituras acima; no momento
+	// da transferência o destino estava cheio.
+	return models.ErrBucketFull
+}
+
+// ApplyMoves verifica e aplica cada movimento dentro da mesma transação; o
+// lock de escrita garante que as leituras não mudam até o commit.
+func (s *SQLiteStore) ApplyMoves(moves []models.FruitMove) error {
+	// As falhas de verificação ficam em moveErr; o erro devolvido para inTx
+	// só serve para desfazer a transação.
+	var moveErr error
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		for i, m := range moves {
+			err := checkMove(tx, m.FruitID, m.FromBucketID, m.ToBucketID)
+			if isRuleError(err) {
+				moveErr = fmt.Errorf("movimento %d: %w", i, err)
+				return errBatchRollback
+			}
+			if err != nil {
+				return err
+			}
+
+			if _, err := tx.Exec("UPDATE fruits SET bucket_id = ? WHERE id = ?", m.ToBucketID, m.FruitID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err == errBatchRollback {
+		return moveErr
+	}
+	if err != nil {
+		log.Println(err)
+	}
+
+	return err
+}
+
+func (s *SQLiteStore) RemoveFruitFromBucket(fruitID, bucketID int) (int64, error) {
+	result, err := s.db.Exec("UPDATE fruits SET bucket_id = NULL WHERE id = ? AND bucket_id = ?", fruitID, bucketID)
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *SQLiteStore) DeleteFruit(id int) error {
+	_, err := s.db.Exec("DELETE FROM fruits WHERE id = ?", id)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// ExpireFruits copia as frutas vencidas para expired_fruits e as remove de
+// fruits na mesma transação. As frutas são lidas antes da cópia, também
+// dentro da transação, para serem devolvidas.
+func (s *SQLiteStore) ExpireFruits(now int64) ([]models.ExpiredFruit, error) {
+	var archived []models.ExpiredFruit
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query("SELECT "+fruitColumns+" FROM fruits WHERE expiration_time <= ? ORDER BY expiration_time, id", now)
+		if err != nil {
+			return err
+		}
+
+		fruits, err := scanFruits(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		if len(fruits) == 0 {
+			return nil
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO expired_fruits (id, name, price_minor, currency, expiration_time, bucket_id, weight, volume, type_id, expired_at)
+			SELECT id, name, price_minor, currency, expiration_time, bucket_id, weight, volume, type_id, ?
+			FROM fruits WHERE expiration_time <= ?`,
+			now, now,
+		)
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec("DELETE FROM fruits WHERE expiration_time <= ?", now); err != nil {
+			return err
+		}
+
+		for _, f := range fruits {
+			archived = append(archived, models.ExpiredFruit{Fruit: f, ExpiredAt: now})
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return archived, nil
+}
+
+func (s *SQLiteStore) ListExpiredFruits(from, to int64) ([]models.ExpiredFruit, error) {
+	query := "SELECT " + fruitColumns + ", expired_at FROM expired_fruits WHERE 1 = 1"
+	var args []any
+	if from != 0 {
+		query += " AND expiration_time >= ?"
+		args = append(args, from)
+	}
+	if to != 0 {
+		query += " AND expiration_time <= ?"
+		args = append(args, to)
+	}
+	query += " ORDER BY expiration_time, id"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fruits []models.ExpiredFruit
+	for rows.Next() {
+		var f models.ExpiredFruit
+		if err := rows.Scan(fruitFields(&f.Fruit, &f.ExpiredAt)...); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		fruits = append(fruits, f)
+	}
+
+	return fruits, rows.Err()
+}
+
+func (s *SQLiteStore) CreateFruitType(t *models.FruitType) error {
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		if err := checkFruitTypeName(tx, t.Name, 0); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(
+			"INSERT INTO fruit_types (name, shelf_life_seconds, default_price_minor, currency) VALUES (?, ?, ?, ?)",
+			t.Name, t.ShelfLifeSeconds, t.DefaultPrice.Amount, t.DefaultPrice.Currency,
+		)
+		if err != nil {
+			return err
+		}
+
+		id, _ := result.LastInsertId()
+		t.ID = int(id)
+
+		return nil
+	})
+	if err != nil && err != models.ErrFruitTypeNameTaken {
+		log.Println(err)
+	}
+
+	return err
+}
+
+func (s *SQLiteStore) GetFruitType(id int) (models.FruitType, error) {
+	t, err := getFruitType(s.db, id)
+	if err != nil && err != models.ErrFruitTypeNotFound {
+		log.Println(err)
+	}
+
+	return t, err
+}
+
+func (s *SQLiteStore) ListFruitTypes() ([]models.FruitType, error) {
+	rows, err := s.db.Query("SELECT " + fruitTypeColumns + " FROM fruit_types ORDER BY id")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var types []models.FruitType
+	for rows.Next() {
+		var t models.FruitType
+		if err := rows.Scan(fruitTypeFields(&t)...); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		types = append(types, t)
+	}
+
+	return types, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateFruitType(id int, update models.UpdateFruitTypeRequest) (models.FruitType, error) {
+	var t models.FruitType
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		current, err := getFruitType(tx, id)
+		if err != nil {
+			return err
+		}
+
+		t = update.Apply(current)
+		if err := checkFruitTypeName(tx, t.Name, id); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"UPDATE fruit_types SET name = ?, shelf_life_seconds = ?, default_price_minor = ?, currency = ? WHERE id = ?",
+			t.Name, t.ShelfLifeSeconds, t.DefaultPrice.Amount, t.DefaultPrice.Currency, id,
+		)
+		return err
+	})
+	if err != nil {
+		if err != models.ErrFruitTypeNotFound && err != models.ErrFruitTypeNameTaken {
+			log.Println(err)
+		}
+		return models.FruitType{}, err
+	}
+
+	return t, nil
+}
+
+// DeleteFruitType verifica as frutas do tipo e exclui o tipo na mesma
+// transação, para que nenhuma fruta nova do tipo apareça no meio do caminho.
+func (s *SQLiteStore) DeleteFruitType(id int) error {
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		if _, err := getFruitType(tx, id); err != nil {
+			return err
+		}
+
+		var inUse bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM fruits WHERE type_id = ?)", id).Scan(&inUse); err != nil {
+			return err
+		}
+		if inUse {
+			return models.ErrFruitTypeInUse
+		}
+
+		_, err := tx.Exec("DELETE FROM fruit_types WHERE id = ?", id)
+		return err
+	})
+	if err != nil && err != models.ErrFruitTypeNotFound && err != models.ErrFruitTypeInUse {
+		log.Println(err)
+	}
+
+	return err
+}
+
+func (s *SQLiteStore) CreateWebhook(w *models.Webhook) error {
+	result, err := s.db.Exec(
+		"INSERT INTO webhooks (url, events, secret, created_at) VALUES (?, ?, ?, ?)",
+		w.URL, strings.Join(w.Events, ","), w.Secret, w.CreatedAt,
+	)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	id, _ := result.LastInsertId()
+	w.ID = int(id)
+
+	return nil
+}
+
+func (s *SQLiteStore) GetWebhook(id int) (models.Webhook, error) {
+	var w models.Webhook
+	var events string
+	err := s.db.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id).Scan(webhookFields(&w, &events)...)
+	if err == sql.ErrNoRows {
+		return w, models.ErrWebhookNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return w, err
+	}
+
+	w.Events = splitEvents(events)
+
+	return w, nil
+}
+
+func (s *SQLiteStore) ListWebhooks() ([]models.Webhook, error) {
+	rows, err := s.db.Query("SELECT " + webhookColumns + " FROM webhooks ORDER BY id")
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var w models.Webhook
+		var events string
+		if err := rows.Scan(webhookFields(&w, &events)...); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		w.Events = splitEvents(events)
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook exclui o webhook; as entregas saem junto pelo ON DELETE
+// CASCADE.
+func (s *SQLiteStore) DeleteWebhook(id int) error {
+	result, err := s.db.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// CreateDelivery verifica o webhook e registra a entrega na mesma transação,
+// para que ela não fique órfã de um webhook excluído no meio do caminho.
+func (s *SQLiteStore) CreateDelivery(d *models.WebhookDelivery) error {
+	err := inTx(s.db, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = ?)", d.WebhookID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return models.ErrWebhookNotFound
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, last_status_code, last_error, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			d.WebhookID, d.Event, string(d.Payload), d.Status, d.Attempts, d.LastStatusCode, d.LastError, d.CreatedAt, d.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		id, _ := result.LastInsertId()
+		d.ID = int(id)
+
+		return nil
+	})
+	if err != nil && err != models.ErrWebhookNotFound {
+		log.Println(err)
+	}
+
+	return err
+}
+
+func (s *SQLiteStore) UpdateDelivery(d models.WebhookDelivery) error {
+	result, err := s.db.Exec(
+		"UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status_code = ?, last_error = ?, updated_at = ? WHERE id = ?",
+		d.Status, d.Attempts, d.LastStatusCode, d.LastError, d.UpdatedAt, d.ID,
+	)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.ErrDeliveryNotFound
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) ListDeliveries(webhookID int) ([]models.WebhookDelivery, error) {
+	return s.queryDeliveries("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC", webhookID)
+}
+
+func (s *SQLiteStore) ListPendingDeliveries() ([]models.WebhookDelivery, error) {
+	return s.queryDeliveries("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE status = ? ORDER BY id", models.DeliveryPending)
+}
+
+// queryDeliveries lê as entregas devolvidas pela consulta.
+func (s *SQLiteStore) queryDeliveries(query string, args ...any) ([]models.WebhookDelivery, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload string
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		d.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// webhookColumns são as colunas lidas de cada webhook, na ordem de
+// webhookFields. Os eventos são guardados separados por vírgula.
+const webhookColumns = "id, url, events, secret, created_at"
+
+func webhookFields(w *models.Webhook, events *string) []any {
+	return []any{&w.ID, &w.URL, events, &w.Secret, &w.CreatedAt}
+}
+
+// splitEvents desfaz a lista de eventos guardada por CreateWebhook.
+func splitEvents(events string) []string {
+	if events == "" {
+		return []string{}
+	}
+
+	return strings.Split(events, ",")
+}
+
+// deliveryColumns são as colunas lidas de cada entrega, na ordem do Scan de
+// queryDeliveries.
+const deliveryColumns = "id, webhook_id, event, payload, status, attempts, last_status_code, last_error, created_at, updated_at"
+
+// fruitTypeColumns são as colunas lidas de cada tipo, na ordem de
+// fruitTypeFields.
+const fruitTypeColumns = "id, name, shelf_life_seconds, default_price_minor, currency"
+
+func fruitTypeFields(t *models.FruitType) []any {
+	return []any{&t.ID, &t.Name, &t.ShelfLifeSeconds, &t.DefaultPrice.Amount, &t.DefaultPrice.Currency}
+}
+
+func getFruitType(q querier, id int) (models.FruitType, error) {
+	var t models.FruitType
+	err := q.QueryRow("SELECT "+fruitTypeColumns+" FROM fruit_types WHERE id = ?", id).Scan(fruitTypeFields(&t)...)
+	if err == sql.ErrNoRows {
+		return t, models.ErrFruitTypeNotFound
+	}
+
+	return t, err
+}
+
+// checkFruitTypeName recusa um nome já usado por outro tipo que não o exceptID.
+// A coluna é COLLATE NOCASE, então a comparação ignora maiúsculas.
+func checkFruitTypeName(q querier, name string, exceptID int) error {
+	var taken bool
+	err := q.QueryRow("SELECT EXISTS (SELECT 1 FROM fruit_types WHERE name = ? AND id <> ?)", name, exceptID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return models.ErrFruitTypeNameTaken
+	}
+
+	return nil
+}
+
+// escapeLike protege os curingas do LIKE para que o texto seja buscado literalmente.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// scanFruits lê todas as frutas de um resultado de consulta.
+func scanFruits(rows *sql.Rows) ([]models.Fruit, error) {
+	var fruits []models.Fruit
+	for rows.Next() {
+		var fruit models.Fruit
+		if err := rows.Scan(fruitFields(&fruit)...); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		fruits = append(fruits, fruit)
+	}
+
+	return fruits, rows.Err()
+}
+
+// fruitColumns são as colunas lidas de cada fruta, na ordem de fruitFields.
+const fruitColumns = "id, name, price_minor, currency, expiration_time, bucket_id, weight, volume, type_id"
+
+// fruitFields devolve os destinos de Scan para fruitColumns, seguidos dos
+// destinos extras informados.
+func fruitFields(f *models.Fruit, extra ...any) []any {
+	return append([]any{&f.ID, &f.Name, &f.Price.Amount, &f.Price.Currency, &f.ExpirationTime, &f.BucketID, &f.Weight, &f.Volume, &f.TypeID}, extra...)
+}
+
+// querier é satisfeito tanto por *sql.DB quanto por *sql.Tx.
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func getFruit(q querier, id int) (models.Fruit, error) {
+	var f models.Fruit
+	err := q.QueryRow("SELECT "+fruitColumns+" FROM fruits WHERE id = ?", id).Scan(fruitFields(&f)...)
+	if err == sql.ErrNoRows {
+		return f, models.ErrFruitNotFound
+	}
+
+	return f, err
+}
+
+// bucketLoad lê o balde e a carga atual dele em cada dimensão.
+func bucketLoad(q querier, bucketID int) (models.Bucket, models.BucketLoad, error) {
+	var b models.Bucket
+	var load models.BucketLoad
+	err := q.QueryRow(`
+		SELECT b.id, b.capacity, b.max_weight, b.max_volume,
+		       COUNT(f.id), COALESCE(SUM(f.weight), 0), COALESCE(SUM(f.volume), 0)
+		FROM buckets b
+		LEFT JOIN fruits f ON f.bucket_id = b.id
+		WHERE b.id = ?
+		GROUP BY b.id`,
+		bucketID,
+	).Scan(&b.ID, &b.Capacity, &b.MaxWeight, &b.MaxVolume, &load.Count, &load.Weight, &load.Volume)
+	if err == sql.ErrNoRows {
+		return b, load, models.ErrBucketNotFound
+	}
+
+	return b, load, err
+}
+
+// fitsInBucket é a condição SQL equivalente a Bucket.CheckFit para a fruta da
+// linha sendo alterada em um UPDATE de fruits. Recebe o ID do balde como
+// único parâmetro.
+const fitsInBucket = `EXISTS (
+		SELECT 1 FROM buckets b
+		WHERE b.id = ?
+		  AND (SELECT COUNT(*) FROM fruits WHERE bucket_id = b.id) < b.capacity
+		  AND (b.max_weight IS NULL OR (SELECT COALESCE(SUM(weight), 0) FROM fruits WHERE bucket_id = b.id) + COALESCE(fruits.weight, 0) <= b.max_weight)
+		  AND (b.max_volume IS NULL OR (SELECT COALESCE(SUM(volume), 0) FROM fruits WHERE bucket_id = b.id) + COALESCE(fruits.volume, 0) <= b.max_volume)
+	)`
+
+// checkDeposit verifica se a fruta pode entrar no balde, na mesma ordem usada
+// pelo store em memória: existência do balde, quantidade, existência da
+// fruta, situação atual dela (state) e, por fim, peso e volume. Devolve nil
+// se o depósito for possível.
+func checkDeposit(q querier, fruitID, bucketID int, state func(models.Fruit) error) error {
+	bucket, load, err := bucketLoad(q, bucketID)
+	if err != nil {
+		return err
+	}
+
+	if load.Count >= bucket.Capacity {
+		return models.ErrBucketFull
+	}
+
+	fruit, err := getFruit(q, fruitID)
+	if err != nil {
+		return err
+	}
+
+	if err := state(fruit); err != nil {
+		return err
+	}
+
+	return bucket.CheckFit(load, fruit)
+}
+
+// checkMove verifica uma transferência. Diferente do depósito, a fruta é
+// verificada antes do destino: uma fruta que não está na origem é recusada
+// mesmo que o destino esteja cheio.
+func checkMove(q querier, fruitID, fromBucketID, toBucketID int) error {
+	fruit, err := getFruit(q, fruitID)
+	if err != nil {
+		return err
+	}
+
+	if err := isIn(fromBucketID)(fruit); err != nil {
+		return err
+	}
+
+	return checkDeposit(q, fruitID, toBucketID, isIn(fromBucketID))
+}
+
+// isLoose exige que a fruta esteja solta, para depósitos.
+func isLoose(f models.Fruit) error {
+	if f.BucketID.Valid {
+		return models.ErrFruitInBucket
+	}
+
+	return nil
+}
+
+// isIn exige que a fruta esteja no balde informado, para transferências.
+func isIn(bucketID int) func(models.Fruit) error {
+	return func(f models.Fruit) error {
+		if !f.BucketID.Valid || int(f.BucketID.Int64) != bucketID {
+			return models.ErrFruitNotInBucket
+		}
+
+		return nil
+	}
+}
+
+// isRuleError informa se err é a violação de uma regra de negócio, e não uma
+// falha do banco.
+func isRuleError(err error) bool {
+	return errors.Is(err, models.ErrNotFound) || errors.Is(err, models.ErrBucketFull) ||
+		errors.Is(err, models.ErrFruitInBucket) || errors.Is(err, models.ErrFruitNotInBucket)
+}
+
+// abortBatch informa se algum depósito do lote falhou e, nesse caso, marca os
+// demais com ErrBatchAborted.
+func abortBatch(results []error) bool {
+	failed := false
+	for _, err := range results {
+		if err != nil {
+			failed = true
+			break
+		}
+	}
+
+	if failed {
+		for i, err := range results {
+			if err == nil {
+				results[i] = models.ErrBatchAborted
+			}
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mr-utzig/planne-test/database/storetest"
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// TestSQLiteStoreConformance executa a suíte compartilhada contra o store SQLite.
+func TestSQLiteStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Store {
+		db, err := InitDBTest()
+		if err != nil {
+			t.Fatalf("InitDBTest: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		return NewSQLiteStore(db)
+	})
+}
+
+// TestSQLiteFileStoreConformance repete a suíte sobre um arquivo, onde o pool
+// usa várias conexões e os depósitos concorrentes de fato competem entre si.
+func TestSQLiteFileStoreConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Store {
+		db, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("InitDB: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		return NewSQLiteStore(db)
+	})
+}
+
+// seedBuckets cria `buckets` baldes com `perBucket` frutas cada um, em uma
+// única transação para que o preparo do benchmark seja rápido.
+func seedBuckets(b *testing.B, db *sql.DB, buckets, perBucket int) {
+	b.Helper()
+
+	expiration := time.Now().Add(time.Hour).Unix()
+	err := inTx(db, func(tx *sql.Tx) error {
+		for i := 1; i <= buckets; i++ {
+			if _, err := tx.Exec("INSERT INTO buckets (id, capacity) VALUES (?, ?)", i, perBucket*2); err != nil {
+				return err
+			}
+
+			for j := 0; j < perBucket; j++ {
+				if _, err := tx.Exec(
+					"INSERT INTO fruits (name, price_minor, expiration_time, bucket_id) VALUES ('Apple', 125, ?, ?)",
+					expiration, i,
+				); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+// benchmarkStore cria um store SQLite em arquivo com dados para os benchmarks.
+func benchmarkStore(b *testing.B) *SQLiteStore {
+	db, err := InitDB(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("InitDB: %v", err)
+	}
+	b.Cleanup(func() { db.Close() })
+
+	seedBuckets(b, db, 2000, 3)
+
+	return NewSQLiteStore(db)
+}
+
+// BenchmarkListBucketsNPlusOne mede a listagem antiga: uma consulta por balde
+// e ordenação em Go.
+func BenchmarkListBucketsNPlusOne(b *testing.B) {
+	store := benchmarkStore(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buckets, err := store.ListBuckets()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		details := make([]models.BucketDetails, 0, len(buckets))
+		for _, bucket := range buckets {
+			fruits, err := store.GetFruitsInBucket(bucket.ID)
+			if err != nil {
+				b.Fatal(err)
+			}
+			details = append(details, models.NewBucketDetails(bucket, fruits))
+		}
+
+		sort.Slice(details, func(i, j int) bool {
+			return details[i].Occupancy > details[j].Occupancy
+		})
+	}
+}
+
+// BenchmarkListBucketDetails mede a listagem com uma única consulta agregada.
+func BenchmarkListBucketDetails(b *testing.B) {
+	store := benchmarkStore(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := store.ListBucketDetails(models.BucketFilter{Desc: true}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,1660 @@
+// Package storetest contém a suíte de conformidade compartilhada pelas
+// implementações dos stores de models.
+package storetest
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// Store é o conjunto de operações exercitadas pela suíte.
+type Store interface {
+	models.BucketStore
+	models.FruitStore
+	models.FruitTypeStore
+	models.WebhookStore
+}
+
+// Run executa a suíte de conformidade. newStore deve devolver um store vazio
+// e independente a cada chamada.
+func Run(t *testing.T, newStore func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s Store)
+	}{
+		{"CreateAndGetBucket", testCreateAndGetBucket},
+		{"ListBuckets", testListBuckets},
+		{"ListBucketDetails", testListBucketDetails},
+		{"ListBucketDetailsFiltered", testListBucketDetailsFiltered},
+		{"ListBucketDetailsPaging", testListBucketDetailsPaging},
+		{"TotalsPerCurrency", testTotalsPerCurrency},
+		{"StoredValuesAreCopies", testStoredValuesAreCopies},
+		{"CreateAndGetFruit", testCreateAndGetFruit},
+		{"CreateFruits", testCreateFruits},
+		{"CreateFruitsRejectsWholeBatch", testCreateFruitsRejectsWholeBatch},
+		{"ListFruits", testListFruits},
+		{"ListFruitsFiltered", testListFruitsFiltered},
+		{"FruitTypes", testFruitTypes},
+		{"DeleteFruitTypeInUse", testDeleteFruitTypeInUse},
+		{"AddFruitToBucket", testAddFruitToBucket},
+		{"AddFruitToFullBucket", testAddFruitToFullBucket},
+		{"AddFruitAlreadyInBucket", testAddFruitAlreadyInBucket},
+		{"AddMissingFruitOrBucket", testAddMissingFruitOrBucket},
+		{"ConcurrentDepositsRespectCapacity", testConcurrentDepositsRespectCapacity},
+		{"ConcurrentDepositsOfSameFruit", testConcurrentDepositsOfSameFruit},
+		{"AddFruitsToBucketBestEffort", testAddFruitsToBucketBestEffort},
+		{"AddFruitsToBucketAllOrNothing", testAddFruitsToBucketAllOrNothing},
+		{"ConcurrentBatchDepositsRespectCapacity", testConcurrentBatchDepositsRespectCapacity},
+		{"WeightAndVolumeLimits", testWeightAndVolumeLimits},
+		{"BatchesRespectWeightLimit", testBatchesRespectWeightLimit},
+		{"BucketDetailsDimensions", testBucketDetailsDimensions},
+		{"MoveFruit", testMoveFruit},
+		{"ConcurrentMovesRespectCapacity", testConcurrentMovesRespectCapacity},
+		{"ApplyMoves", testApplyMoves},
+		{"ApplyMovesRollsBack", testApplyMovesRollsBack},
+		{"UpdateBucketCapacity", testUpdateBucketCapacity},
+		{"UpdateBucketExpiryWarning", testUpdateBucketExpiryWarning},
+		{"UpdateBucketAtomic", testUpdateBucketAtomic},
+		{"UpdateFruit", testUpdateFruit},
+		{"RemoveFruitFromBucket", testRemoveFruitFromBucket},
+		{"DeleteBucketReleasesFruits", testDeleteBucketReleasesFruits},
+		{"DeleteFruit", testDeleteFruit},
+		{"ExpireFruitsArchives", testExpireFruitsArchives},
+		{"ListExpiredFruitsByRange", testListExpiredFruitsByRange},
+		{"Webhooks", testWebhooks},
+		{"WebhookDeliveries", testWebhookDeliveries},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// mustCreateBucket cria um balde e falha o teste em caso de erro.
+func mustCreateBucket(t *testing.T, s Store, capacity int) models.Bucket {
+	t.Helper()
+
+	b := models.Bucket{Capacity: capacity}
+	if err := s.CreateBucket(&b); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	return b
+}
+
+// brl cria um preço em reais a partir dos centavos.
+func brl(cents int64) models.Money {
+	return models.Money{Amount: cents, Currency: "BRL"}
+}
+
+// mustCreateFruit cria uma fruta solta, com o preço em centavos de real, que
+// expira em 1 hora.
+func mustCreateFruit(t *testing.T, s Store, name string, price int64) models.Fruit {
+	t.Helper()
+
+	f := models.Fruit{Name: name, Price: brl(price), ExpirationTime: time.Now().Add(time.Hour).Unix()}
+	if err := s.CreateFruit(&f); err != nil {
+		t.Fatalf("CreateFruit: %v", err)
+	}
+
+	return f
+}
+
+func testCreateAndGetBucket(t *testing.T, s Store) {
+	b := mustCreateBucket(t, s, 3)
+	if b.ID == 0 {
+		t.Fatalf("Expected bucket ID to be set")
+	}
+
+	got, err := s.GetBucket(b.ID)
+	if err != nil {
+		t.Fatalf("GetBucket: %v", err)
+	}
+	if got != b {
+		t.Errorf("Expected %+v. Got %+v", b, got)
+	}
+
+	if _, err := s.GetBucket(b.ID + 100); !errors.Is(err, models.ErrBucketNotFound) {
+		t.Errorf("Expected ErrBucketNotFound. Got %v", err)
+	}
+}
+
+func testListBuckets(t *testing.T, s Store) {
+	buckets, err := s.ListBuckets()
+	if err != nil {
+		t.Fatalf("ListBuckets: %v", err)
+	}
+	if len(buckets) != 0 {
+		t.Fatalf("Expected no buckets. Got %d", len(buckets))
+	}
+
+	b1 := mustCreateBucket(t, s, 1)
+	b2 := mustCreateBucket(t, s, 2)
+
+	buckets, err = s.ListBuckets()
+	if err != nil {
+		t.Fatalf("ListBuckets: %v", err)
+	}
+	if len(buckets) != 2 || buckets[0] != b1 || buckets[1] != b2 {
+		t.Errorf("Expected [%+v %+v]. Got %+v", b1, b2, buckets)
+	}
+}
+
+func testListBucketDetails(t *testing.T, s Store) {
+	empty := mustCreateBucket(t, s, 2)
+	half := mustCreateBucket(t, s, 4)
+	full := mustCreateBucket(t, s, 1)
+
+	for _, deposit := range []struct {
+		bucket int
+		price  int64
+	}{{half.ID, 150}, {half.ID, 250}, {full.ID, 300}} {
+		f := mustCreateFruit(t, s, "Apple", deposit.price)
+		if err := s.AddFruitToBucket(f.ID, deposit.bucket); err != nil {
+			t.Fatalf("AddFruitToBucket: %v", err)
+		}
+	}
+
+	details, err := s.ListBucketDetails(models.BucketFilter{Desc: true})
+	if err != nil {
+		t.Fatalf("ListBucketDetails: %v", err)
+	}
+
+	want := []struct {
+		id         int
+		count      int
+		total      int64
+		occupancy  float64
+		fruitCount int
+	}{
+		{full.ID, 1, 300, 100, 1},
+		{half.ID, 2, 400, 50, 2},
+		{empty.ID, 0, 0, 0, 0},
+	}
+
+	if len(details) != len(want) {
+		t.Fatalf("Expected %d buckets. Got %+v", len(want), details)
+	}
+	for i, w := range want {
+		d := details[i]
+		if d.ID != w.id || d.FruitCount != w.count || d.TotalValue["BRL"] != w.total || d.Occupancy != w.occupancy || len(d.Fruits) != w.fruitCount {
+			t.Errorf("Position %d: Expected %+v. Got %+v", i, w, d)
+		}
+		for _, f := range d.Fruits {
+			if !f.BucketID.Valid || int(f.BucketID.Int64) != d.ID {
+				t.Errorf("Expected fruit %d to report bucket %d. Got %+v", f.ID, d.ID, f.BucketID)
+			}
+		}
+	}
+}
+
+// seedOccupancy cria um balde por capacidade informada, depositando a
+// quantidade de frutas correspondente, e devolve os IDs na mesma ordem.
+func seedOccupancy(t *testing.T, s Store, buckets [][2]int) []int {
+	t.Helper()
+
+	ids := make([]int, len(buckets))
+	for i, spec := range buckets {
+		b := mustCreateBucket(t, s, spec[0])
+		for j := 0; j < spec[1]; j++ {
+			f := mustCreateFruit(t, s, "Apple", 100)
+			if err := s.AddFruitToBucket(f.ID, b.ID); err != nil {
+				t.Fatalf("AddFruitToBucket: %v", err)
+			}
+		}
+		ids[i] = b.ID
+	}
+
+	return ids
+}
+
+// bucketIDs extrai os IDs da listagem, para comparações.
+func bucketIDs(details []models.BucketDetails) []int {
+	ids := make([]int, len(details))
+	for i, d := range details {
+		ids[i] = d.ID
+	}
+
+	return ids
+}
+
+func fruitIDsOf(fruits []models.Fruit) []int {
+	ids := make([]int, len(fruits))
+	for i, f := range fruits {
+		ids[i] = f.ID
+	}
+
+	return ids
+}
+
+func sameIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func testListBucketDetailsFiltered(t *testing.T, s Store) {
+	// Capacidade e quantidade de frutas de cada balde
+	ids := seedOccupancy(t, s, [][2]int{{4, 0}, {4, 1}, {2, 2}, {4, 2}, {1, 0}})
+
+	yes, no := true, false
+
+	tests := []struct {
+		name   string
+		filter models.BucketFilter
+		want   []int
+	}{
+		{"occupancy desc", models.BucketFilter{Desc: true}, []int{ids[2], ids[3], ids[1], ids[4], ids[0]}},
+		{"capacity asc", models.BucketFilter{SortBy: models.BucketSortCapacity}, []int{ids[4], ids[2], ids[0], ids[1], ids[3]}},
+		{"total value desc", models.BucketFilter{SortBy: models.BucketSortTotalValue, Desc: true}, []int{ids[3], ids[2], ids[1], ids[4], ids[0]}},
+		{"id desc", models.BucketFilter{SortBy: models.BucketSortID, Desc: true}, []int{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{"min occupancy", models.BucketFilter{MinOccupancy: 50}, []int{ids[3], ids[2]}},
+		{"empty", models.BucketFilter{SortBy: models.BucketSortID, Empty: &yes}, []int{ids[0], ids[4]}},
+		{"not empty", models.BucketFilter{SortBy: models.BucketSortID, Empty: &no}, []int{ids[1], ids[2], ids[3]}},
+		{"full", models.BucketFilter{Full: &yes}, []int{ids[2]}},
+		{"limit", models.BucketFilter{Desc: true, Limit: 2}, []int{ids[2], ids[3]}},
+	}
+
+	for _, tt := range tests {
+		details, err := s.ListBucketDetails(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: ListBucketDetails: %v", tt.name, err)
+		}
+
+		if got := bucketIDs(details); !sameIDs(got, tt.want) {
+			t.Errorf("%s: Expected %v. Got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func testTotalsPerCurrency(t *testing.T, s Store) {
+	a := mustCreateBucket(t, s, 4)
+	b := mustCreateBucket(t, s, 4)
+
+	for _, deposit := range []struct {
+		bucket int
+		price  models.Money
+	}{
+		{a.ID, brl(1000)},
+		{a.ID, models.Money{Amount: 500, Currency: "USD"}},
+		{a.ID, models.Money{Amount: 5, Currency: "USD"}},
+		{b.ID, brl(200)},
+		{b.ID, models.Money{Amount: 2000, Currency: "USD"}},
+	} {
+		f := models.Fruit{Name: "Apple", Price: deposit.price, ExpirationTime: time.Now().Add(time.Hour).Unix()}
+		if err := s.CreateFruit(&f); err != nil {
+			t.Fatalf("CreateFruit: %v", err)
+		}
+		if err := s.AddFruitToBucket(f.ID, deposit.bucket); err != nil {
+			t.Fatalf("AddFruitToBucket: %v", err)
+		}
+	}
+
+	details, err := s.ListBucketDetails(models.BucketFilter{SortBy: models.BucketSortID})
+	if err != nil {
+		t.Fatalf("ListBucketDetails: %v", err)
+	}
+	if len(details) != 2 || details[0].TotalValue["BRL"] != 1000 || details[0].TotalValue["USD"] != 505 ||
+		details[1].TotalValue["BRL"] != 200 || details[1].TotalValue["USD"] != 2000 {
+		t.Fatalf("Expected totals per currency. Got %+v", details)
+	}
+
+	// Sem frutas em euro, os dois empatam e o ID desempata.
+	for currency, want := range map[string][]int{"BRL": {a.ID, b.ID}, "USD": {b.ID, a.ID}, "EUR": {b.ID, a.ID}} {
+		details, err := s.ListBucketDetails(models.BucketFilter{SortBy: models.BucketSortTotalValue, Currency: currency, Desc: true})
+		if err != nil {
+			t.Fatalf("ListBucketDetails: %v", err)
+		}
+		if got := bucketIDs(details); !sameIDs(got, want) {
+			t.Errorf("%s: Expected %v. Got %v", currency, want, got)
+		}
+	}
+
+	fruits, err := s.ListFruits(models.FruitFilter{Currency: "USD", MinPrice: 100, SortBy: models.FruitSortPrice})
+	if err != nil {
+		t.Fatalf("ListFruits: %v", err)
+	}
+	if len(fruits) != 2 || fruits[0].Price.Amount != 500 || fruits[1].Price.Amount != 2000 {
+		t.Errorf("Expected the USD fruits from 1.00 up. Got %+v", fruits)
+	}
+}
+
+func testListBucketDetailsPaging(t *testing.T, s Store) {
+	// Vários empates de ocupação para exercitar o desempate pelo ID
+	seedOccupancy(t, s, [][2]int{{2, 1}, {4, 2}, {2, 0}, {2, 2}, {4, 0}, {2, 1}, {1, 1}})
+
+	for _, desc := range []bool{true, false} {
+		all, err := s.ListBucketDetails(models.BucketFilter{Desc: desc})
+		if err != nil {
+			t.Fatalf("ListBucketDetails: %v", err)
+		}
+
+		var paged []models.BucketDetails
+		filter := models.BucketFilter{Desc: desc, Limit: 2}
+		for {
+			page, err := s.ListBucketDetails(filter)
+			if err != nil {
+				t.Fatalf("ListBucketDetails: %v", err)
+			}
+			if len(page) == 0 {
+				break
+			}
+
+			paged = append(paged, page...)
+			cursor := page[len(page)-1].Cursor(filter)
+			filter.After = &cursor
+		}
+
+		if want, got := bucketIDs(all), bucketIDs(paged); !sameIDs(got, want) {
+			t.Errorf("desc=%v: Expected pages to cover %v. Got %v", desc, want, got)
+		}
+	}
+}
+
+// testStoredValuesAreCopies verifica que alterar os campos ponteiro de um
+// valor gravado ou devolvido não altera o que está guardado no store.
+func testStoredValuesAreCopies(t *testing.T, s Store) {
+	weight, warning := 2.5, int64(600)
+	b := models.Bucket{Capacity: 3, MaxWeight: &weight, ExpiryWarningSeconds: &warning}
+	if err := s.CreateBucket(&b); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+	weight, warning = 99, 1
+
+	got, _ := s.GetBucket(b.ID)
+	if got.MaxWeight == nil || *got.MaxWeight != 2.5 || got.ExpiryWarningSeconds == nil || *got.ExpiryWarningSeconds != 600 {
+		t.Fatalf("Expected the stored bucket to keep its limits. Got %+v", got)
+	}
+	*got.MaxWeight = 99
+
+	if again, _ := s.GetBucket(b.ID); *again.MaxWeight != 2.5 {
+		t.Errorf("Expected a returned bucket not to share its limits. Got %v", *again.MaxWeight)
+	}
+
+	ft := mustCreateFruitType(t, s, "Banana")
+	fruitWeight, typeID := 0.5, ft.ID
+	f := models.Fruit{Name: "Banana", Price: brl(75), ExpirationTime: time.Now().Add(time.Hour).Unix(), Weight: &fruitWeight, TypeID: &typeID}
+	if err := s.CreateFruit(&f); err != nil {
+		t.Fatalf("CreateFruit: %v", err)
+	}
+	fruitWeight, typeID = 99, ft.ID+100
+
+	fruit, _ := s.GetFruit(f.ID)
+	if fruit.Weight == nil || *fruit.Weight != 0.5 || fruit.TypeID == nil || *fruit.TypeID != ft.ID {
+		t.Fatalf("Expected the stored fruit to keep its fields. Got %+v", fruit)
+	}
+	*fruit.Weight = 99
+
+	if again, _ := s.GetFruit(f.ID); *again.Weight != 0.5 {
+		t.Errorf("Expected a returned fruit not to share its weight. Got %v", *again.Weight)
+	}
+}
+
+func testCreateAndGetFruit(t *testing.T, s Store) {
+	f := mustCreateFruit(t, s, "Apple", 150)
+	if f.ID == 0 {
+		t.Fatalf("Expected fruit ID to be set")
+	}
+
+	got, err := s.GetFruit(f.ID)
+	if err != nil {
+		t.Fatalf("GetFruit: %v", err)
+	}
+	if got != f {
+		t.Errorf("Expected %+v. Got %+v", f, got)
+	}
+
+	if _, err := s.GetFruit(f.ID + 100); !errors.Is(err, models.ErrFruitNotFound) {
+		t.Errorf("Expected ErrFruitNotFound. Got %v", err)
+	}
+
+	orphan := models.Fruit{
+		Name:           "Orphan",
+		Price:          brl(100),
+		ExpirationTime: time.Now().Add(time.Hour).Unix(),
+		BucketID:       sql.NullInt64{Int64: 999, Valid: true},
+	}
+	if err := s.CreateFruit(&orphan); !errors.Is(err, models.ErrBucketNotFound) {
+		t.Errorf("Expected ErrBucketNotFound for missing bucket. Got %v", err)
+	}
+}
+
+// batchFruit monta uma fruta para os testes de criação em lote; bucketID
+// zero significa fruta solta.
+func batchFruit(name string, bucketID int) models.Fruit {
+	f := models.Fruit{Name: name, Price: brl(100), ExpirationTime: time.Now().Add(time.Hour).Unix()}
+	if bucketID != 0 {
+		f.BucketID = sql.NullInt64{Int64: int64(bucketID), Valid: true}
+	}
+
+	return f
+}
+
+func testCreateFruits(t *testing.T, s Store) {
+	b := mustCreateBucket(t, s, 2)
+
+	fruits := []models.Fruit{batchFruit("Apple", b.ID), batchFruit("Orange", 0), batchFruit("Pear", b.ID)}
+	errs, err := s.CreateFruits(fruits)
+	if err != nil {
+		t.Fatalf("CreateFruits: %v", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Item %d: Expected success. Got %v", i, err)
+		}
+	}
+
+	for _, f := range fruits {
+		got, err := s.GetFruit(f.ID)
+		if err != nil || got.Name != f.Name || got.BucketID != f.BucketID {
+			t.Errorf("Expected %+v to be stored. Got %+v (%v)", f, got, err)
+		}
+	}
+
+	inBucket, _ := s.GetFruitsInBucket(b.ID)
+	if !sameIDs(fruitIDsOf(inBucket), []int{fruits[0].ID, fruits[2].ID}) {
+		t.Errorf("Expected fruits %d and %d in bucket. Got %+v", fruits[0].ID, fruits[2].ID, inBucket)
+	}
+}
+
+func testCreateFruitsRejectsWholeBatch(t *testing.T, s Store) {
+	ids := seedOccupancy(t, s, [][2]int{{2, 1}})
+	b := ids[0]
+
+	// A primeira fruta ocupa a última vaga, então a terceira encontra o
+	// balde cheio.
+	fruits := []models.Fruit{batchFruit("Apple", b), batchFruit("Orange", b+100), batchFruit("Pear", b), batchFruit("Kiwi", 0)}
+	errs, err := s.CreateFruits(fruits)
+	if err != nil {
+		t.Fatalf("CreateFruits: %v", err)
+	}
+
+	want := []error{nil, models.ErrBucketNotFound, models.ErrBucketFull, nil}
+	for i := range want {
+		if !errors.Is(errs[i], want[i]) {
+			t.Errorf("Item %d: Expected %v. Got %v", i, want[i], errs[i])
+		}
+	}
+
+	all, _ := s.ListFruits(models.FruitFilter{})
+	if len(all) != 1 {
+		t.Errorf("Expected rejected batch to create nothing. Got %+v", all)
+	}
+}
+
+func testListFruits(t *testing.T, s Store) {
+	b := mustCreateBucket(t, s, 1)
+	f1 := mustCreateFruit(t, s, "Apple", 100)
+	f2 := mustCreateFruit(t, s, "Orange", 200)
+	if err := s.AddFruitToBucket(f2.ID, b.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+
+	fruits, err := s.ListFruits(models.FruitFilter{})
+	if err != nil {
+		t.Fatalf("ListFruits: %v", err)
+	}
+	if len(fruits) != 2 || fruits[0].ID != f1.ID || fruits[1].ID != f2.ID {
+		t.Errorf("Expected loose and bucketed fruits ordered by ID. Got %+v", fruits)
+	}
+}
+
+// mustCreateFruitType cria um tipo de fruta e falha o teste em caso de erro.
+func mustCreateFruitType(t *testing.T, s Store, name string) models.FruitType {
+	t.Helper()
+
+	ft := models.FruitType{Name: name, ShelfLifeSeconds: 3600, DefaultPrice: brl(75)}
+	if err := s.CreateFruitType(&ft); err != nil {
+		t.Fatalf("CreateFruitType: %v", err)
+	}
+
+	return ft
+}
+
+func testFruitTypes(t *testing.T, s Store) {
+	banana := mustCreateFruitType(t, s, "Banana")
+	apple := mustCreateFruitType(t, s, "Apple")
+	if banana.ID == 0 || apple.ID == banana.ID {
+		t.Fatalf("Expected distinct IDs. Got %d and %d", banana.ID, apple.ID)
+	}
+
+	got, err := s.GetFruitType(banana.ID)
+	if err != nil {
+		t.Fatalf("GetFruitType: %v", err)
+	}
+	if got != banana {
+		t.Errorf("Expected %+v. Got %+v", banana, got)
+	}
+
+	if _, err := s.GetFruitType(apple.ID + 100); !errors.Is(err, models.ErrFruitTypeNotFound) {
+		t.Errorf("Expected ErrFruitTypeNotFound. Got %v", err)
+	}
+
+	dup := models.FruitType{Name: "BANANA", ShelfLifeSeconds: 60, DefaultPrice: brl(100)}
+	if err := s.CreateFruitType(&dup); !errors.Is(err, models.ErrFruitTypeNameTaken) {
+		t.Errorf("Expected ErrFruitTypeNameTaken. Got %v", err)
+	}
+
+	price := brl(50)
+	updated, err := s.UpdateFruitType(banana.ID, models.UpdateFruitTypeRequest{DefaultPrice: &price})
+	if err != nil {
+		t.Fatalf("UpdateFruitType: %v", err)
+	}
+	if updated.DefaultPrice != brl(50) || updated.Name != "Banana" || updated.ShelfLifeSeconds != 3600 {
+		t.Errorf("Expected only the price to change. Got %+v", updated)
+	}
+
+	name := "apple"
+	if _, err := s.UpdateFruitType(banana.ID, models.UpdateFruitTypeRequest{Name: &name}); !errors.Is(err, models.ErrFruitTypeNameTaken) {
+		t.Errorf("Expected ErrFruitTypeNameTaken. Got %v", err)
+	}
+	// Mudar só a grafia do próprio nome é permitido.
+	name = "banana"
+	if _, err := s.UpdateFruitType(banana.ID, models.UpdateFruitTypeRequest{Name: &name}); err != nil {
+		t.Errorf("Expected rename to succeed. Got %v", err)
+	}
+
+	if err := s.DeleteFruitType(apple.ID); err != nil {
+		t.Fatalf("DeleteFruitType: %v", err)
+	}
+	if err := s.DeleteFruitType(apple.ID); !errors.Is(err, models.ErrFruitTypeNotFound) {
+		t.Errorf("Expected ErrFruitTypeNotFound. Got %v", err)
+	}
+
+	types, err := s.ListFruitTypes()
+	if err != nil {
+		t.Fatalf("ListFruitTypes: %v", err)
+	}
+	if len(types) != 1 || types[0].ID != banana.ID || types[0].Name != "banana" {
+		t.Errorf("Expected only the renamed banana type. Got %+v", types)
+	}
+}
+
+func testDeleteFruitTypeInUse(t *testing.T, s Store) {
+	ft := mustCreateFruitType(t, s, "Banana")
+
+	typed := models.Fruit{Name: "Banana", Price: brl(75), ExpirationTime: time.Now().Add(time.Hour).Unix(), TypeID: &ft.ID}
+	if err := s.CreateFruit(&typed); err != nil {
+		t.Fatalf("CreateFruit: %v", err)
+	}
+	mustCreateFruit(t, s, "Apple", 100)
+
+	got, err := s.GetFruit(typed.ID)
+	if err != nil {
+		t.Fatalf("GetFruit: %v", err)
+	}
+	if got.TypeID == nil || *got.TypeID != ft.ID {
+		t.Errorf("Expected type %d. Got %v", ft.ID, got.TypeID)
+	}
+
+	fruits, err := s.ListFruits(models.FruitFilter{TypeID: ft.ID})
+	if err != nil {
+		t.Fatalf("ListFruits: %v", err)
+	}
+	if !sameIDs(fruitIDsOf(fruits), []int{typed.ID}) {
+		t.Errorf("Expected only fruit %d. Got %+v", typed.ID, fruits)
+	}
+
+	if err := s.DeleteFruitType(ft.ID); !errors.Is(err, models.ErrFruitTypeInUse) {
+		t.Errorf("Expected ErrFruitTypeInUse. Got %v", err)
+	}
+
+	// Frutas arquivadas não prendem o tipo.
+	if _, err := s.ExpireFruits(typed.ExpirationTime); err != nil {
+		t.Fatalf("ExpireFruits: %v", err)
+	}
+	if err := s.DeleteFruitType(ft.ID); err != nil {
+		t.Errorf("Expected delete after expiration to succeed. Got %v", err)
+	}
+}
+
+func testListFruitsFiltered(t *testing.T, s Store) {
+	now := time.Now().Unix()
+
+	b1 := mustCreateBucket(t, s, 5)
+	b2 := mustCreateBucket(t, s, 5)
+
+	seed := []struct {
+		name    string
+		price   int64
+		expires int64
+		bucket  int
+	}{
+		{"Apple", 100, now + 100, b1.ID},
+		{"Pineapple", 500, now + 300, b1.ID},
+		{"Orange", 200, now + 200, b2.ID},
+		{"apple_green", 150, now + 400, 0},
+		{"Banana", 50, now + 50, 0},
+	}
+
+	ids := make(map[string]int)
+	for _, sf := range seed {
+		f := models.Fruit{Name: sf.name, Price: brl(sf.price), ExpirationTime: sf.expires}
+		if sf.bucket != 0 {
+			f.BucketID = sql.NullInt64{Int64: int64(sf.bucket), Valid: true}
+		}
+		if err := s.CreateFruit(&f); err != nil {
+			t.Fatalf("CreateFruit: %v", err)
+		}
+		ids[sf.name] = f.ID
+	}
+
+	inBucket, loose := true, false
+
+	tests := []struct {
+		name   string
+		filter models.FruitFilter
+		want   []string
+	}{
+		{"in bucket", models.FruitFilter{InBucket: &inBucket}, []string{"Apple", "Pineapple", "Orange"}},
+		{"loose", models.FruitFilter{InBucket: &loose}, []string{"apple_green", "Banana"}},
+		{"bucket id", models.FruitFilter{BucketID: b2.ID}, []string{"Orange"}},
+		{"name substring", models.FruitFilter{Name: "APPLE"}, []string{"Apple", "Pineapple", "apple_green"}},
+		{"name with wildcard", models.FruitFilter{Name: "e_g"}, []string{"apple_green"}},
+		{"price range", models.FruitFilter{Currency: "BRL", MinPrice: 100, MaxPrice: 200}, []string{"Apple", "Orange", "apple_green"}},
+		{"expires before", models.FruitFilter{ExpiresBefore: now + 200}, []string{"Apple", "Banana"}},
+		{"expires after", models.FruitFilter{ExpiresAfter: now + 200}, []string{"Pineapple", "apple_green"}},
+		{"sort by price desc", models.FruitFilter{SortBy: models.FruitSortPrice, Desc: true}, []string{"Pineapple", "Orange", "apple_green", "Apple", "Banana"}},
+		{"sort by name", models.FruitFilter{SortBy: models.FruitSortName}, []string{"Apple", "Banana", "Orange", "Pineapple", "apple_green"}},
+		{"sort by expiration", models.FruitFilter{SortBy: models.FruitSortExpiration}, []string{"Banana", "Apple", "Orange", "Pineapple", "apple_green"}},
+		{"page", models.FruitFilter{SortBy: models.FruitSortExpiration, Limit: 2, Offset: 1}, []string{"Apple", "Orange"}},
+		{"offset past end", models.FruitFilter{Offset: 10}, nil},
+	}
+
+	for _, tt := range tests {
+		fruits, err := s.ListFruits(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: ListFruits: %v", tt.name, err)
+		}
+
+		if len(fruits) != len(tt.want) {
+			t.Errorf("%s: Expected %v. Got %+v", tt.name, tt.want, fruits)
+			continue
+		}
+		for i, name := range tt.want {
+			if fruits[i].ID != ids[name] {
+				t.Errorf("%s: Expected %v. Got %+v", tt.name, tt.want, fruits)
+				break
+			}
+		}
+	}
+}
+
+func testAddFruitToBucket(t *testing.T, s Store) {
+	b := mustCreateBucket(t, s, 2)
+	f := mustCreateFruit(t, s, "Apple", 100)
+
+	if err := s.AddFruitToBucket(f.ID, b.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+
+	got, _ := s.GetFruit(f.ID)
+	if !got.BucketID.Valid || int(got.BucketID.Int64) != b.ID {
+		t.Errorf("Expected fruit to be in bucket %d. Got %+v", b.ID, got.BucketID)
+	}
+
+	fruits, err := s.GetFruitsInBucket(b.ID)
+	if err != nil {
+		t.Fatalf("GetFruitsInBucket: %v", err)
+	}
+	if len(fruits) != 1 || fruits[0].ID != f.ID {
+		t.Errorf("Expected bucket to hold fruit %d. Got %+v", f.ID, fruits)
+	}
+}
+
+func testAddFruitToFullBucket(t *testing.T, s Store) {
+	b := mustCreateBucket(t, s, 1)
+	f1 := mustCreateFruit(t, s, "Apple", 100)
+	f2 := mustCreateFruit(t, s, "Orange", 100)
+
+	if err := s.AddFruitToBucket(f1.ID, b.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+	if err := s.AddFruitToBucket(f2.ID, b.ID); !errors.Is(err, models.ErrBucketFull) {
+		t.Errorf("Expected ErrBucketFull. Got %v", err)
+	}
+
+	got, _ := s.GetFruit(f2.ID)
+	if got.BucketID.Valid {
+		t.Errorf("Expected fruit to stay out of the bucket. Got %+v", got.BucketID)
+	}
+}
+
+func testAddFruitAlreadyInBucket(t *testing.T, s Store) {
+	b1 := mustCreateBucket(t, s, 2)
+	b2 := mustCreateBucket(t, s, 2)
+	f := mustCreateFruit(t, s, "Apple", 100)
+
+	if err := s.AddFruitToBucket(f.ID, b1.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+	if err := s.AddFruitToBucket(f.ID, b2.ID); !errors.Is(err, models.ErrFruitInBucket) {
+		t.Errorf("Expected ErrFruitInBucket. Got %v", err)
+	}
+}
+
+func testAddMissingFruitOrBucket(t *testing.T, s Store) {
+	b := mustCreateBucket(t, s, 1)
+	f := mustCreateFruit(t, s, "Apple", 100)
+
+	if err := s.AddFruitToBucket(f.ID, b.ID+100); !errors.Is(err, models.ErrBucketNotFound) {
+		t.Errorf("Expected ErrBucketNotFound. Got %v", err)
+	}
+	if err := s.AddFruitToBucket(f.ID+100, b.ID); !errors.Is(err, models.ErrFruitNotFound) {
+		t.Errorf("Expected ErrFruitNotFound. Got %v", err)
+	}
+}
+
+// depositConcurrently deposita cada par (fruta, balde) em uma goroutine
+// própria, liberando todas ao mesmo tempo, e devolve os erros na mesma ordem.
+func depositConcurrently(s Store, fruitIDs, bucketIDs []int) []error {
+	errs := make([]error, len(fruitIDs))
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := range fruitIDs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = s.AddFruitToBucket(fruitIDs[i], bucketIDs[i])
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	return errs
+}
+
+func testConcurrentDepositsRespectCapacity(t *testing.T, s Store) {
+	const capacity, attempts = 5, 50
+
+	b := mustCreateBucket(t, s, capacity)
+
+	fruitIDs := make([]int, attempts)
+	bucketIDs := make([]int, attempts)
+	for i := range fruitIDs {
+		fruitIDs[i] = mustCreateFruit(t, s, "Apple", 100).ID
+		bucketIDs[i] = b.ID
+	}
+
+	succeeded := 0
+	for _, err := range depositConcurrently(s, fruitIDs, bucketIDs) {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, models.ErrBucketFull):
+			t.Errorf("Expected ErrBucketFull. Got %v", err)
+		}
+	}
+
+	if succeeded != capacity {
+		t.Errorf("Expected %d successful deposits. Got %d", capacity, succeeded)
+	}
+
+	fruits, err := s.GetFruitsInBucket(b.ID)
+	if err != nil {
+		t.Fatalf("GetFruitsInBucket: %v", err)
+	}
+	if len(fruits) != capacity {
+		t.Errorf("Expected bucket to hold %d fruits. Got %d", capacity, len(fruits))
+	}
+}
+
+func testConcurrentDepositsOfSameFruit(t *testing.T, s Store) {
+	const attempts = 20
+
+	f := mustCreateFruit(t, s, "Apple", 100)
+
+	fruitIDs := make([]int, attempts)
+	bucketIDs := make([]int, attempts)
+	for i := range bucketIDs {
+		fruitIDs[i] = f.ID
+		bucketIDs[i] = mustCreateBucket(t, s, 1).ID
+	}
+
+	succeeded := 0
+	for _, err := range depositConcurrently(s, fruitIDs, bucketIDs) {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, models.ErrFruitInBucket):
+			t.Errorf("Expected ErrFruitInBucket. Got %v", err)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one successful deposit. Got %d", succeeded)
+	}
+}
+
+func testAddFruitsToBucketBestEffort(t *testing.T, s Store) {
+	ids := seedOccupancy(t, s, [][2]int{{2, 0}, {1, 1}})
+	b, other := ids[0], ids[1]
+
+	f1 := mustCreateFruit(t, s, "Apple", 100)
+	f2 := mustCreateFruit(t, s, "Orange", 100)
+	f3 := mustCreateFruit(t, s, "Pear", 100)
+	placed, _ := s.GetFruitsInBucket(other)
+
+	fruitIDs := []int{f1.ID, f1.ID, placed[0].ID, f1.ID + 100, f2.ID, f3.ID}
+	want := []error{nil, models.ErrFruitInBucket, models.ErrFruitInBucket, models.ErrFruitNotFound, nil, models.ErrBucketFull}
+
+	// As falhas não ocupam vaga: f2 ainda cabe e só f3 encontra o balde cheio.
+	got, err := s.AddFruitsToBucket(b, fruitIDs, false)
+	if err != nil {
+		t.Fatalf("AddFruitsToBucket: %v", err)
+	}
+	for i := range want {
+		if !errors.Is(got[i], want[i]) {
+			t.Errorf("Item %d: Expected %v. Got %v", i, want[i], got[i])
+		}
+	}
+
+	fruits, _ := s.GetFruitsInBucket(b)
+	if !sameIDs(fruitIDsOf(fruits), []int{f1.ID, f2.ID}) {
+		t.Errorf("Expected fruits %v in bucket. Got %+v", []int{f1.ID, f2.ID}, fruits)
+	}
+
+	if _, err := s.AddFruitsToBucket(b+100, []int{f3.ID}, false); !errors.Is(err, models.ErrBucketNotFound) {
+		t.Errorf("Expected ErrBucketNotFound. Got %v", err)
+	}
+}
+
+func testAddFruitsToBucketAllOrNothing(t *testing.T, s Store) {
+	b := mustCreateBucket(t, s, 2)
+	f1 := mustCreateFruit(t, s, "Apple", 100)
+	f2 := mustCreateFruit(t, s, "Orange", 100)
+	f3 := mustCreateFruit(t, s, "Pear", 100)
+
+	got, err := s.AddFruitsToBucket(b.ID, []int{f1.ID, f2.ID, f3.ID}, true)
+	if err != nil {
+		t.Fatalf("AddFruitsToBucket: %v", err)
+	}
+	want := []error{models.ErrBatchAborted, models.ErrBatchAborted, models.ErrBucketFull}
+	for i := range want {
+		if !errors.Is(got[i], want[i]) {
+			t.Errorf("Item %d: Expected %v. Got %v", i, want[i], got[i])
+		}
+	}
+
+	if fruits, _ := s.GetFruitsInBucket(b.ID); len(fruits) != 0 {
+		t.Errorf("Expected rejected batch to leave bucket empty. Got %+v", fruits)
+	}
+
+	got, err = s.AddFruitsToBucket(b.ID, []int{f1.ID, f2.ID}, true)
+	if err != nil {
+		t.Fatalf("AddFruitsToBucket: %v", err)
+	}
+	for i, err := range got {
+		if err != nil {
+			t.Errorf("Item %d: Expected success. Got %v", i, err)
+		}
+	}
+
+	fruits, _ := s.GetFruitsInBucket(b.ID)
+	if !sameIDs(fruitIDsOf(fruits), []int{f1.ID, f2.ID}) {
+		t.Errorf("Expected fruits %v in bucket. Got %+v", []int{f1.ID, f2.ID}, fruits)
+	}
+}
+
+// ptr devolve um ponteiro para o valor, para os campos opcionais.
+func ptr(v float64) *float64 {
+	return &v
+}
+
+// mustCreateLimitedBucket cria um balde com peso e volume máximos.
+func mustCreateLimitedBucket(t *testing.T, s Store, capacity int, maxWeight, maxVolume *float64) models.Bucket {
+	t.Helper()
+
+	b := models.Bucket{Capacity: capacity, MaxWeight: maxWeight, MaxVolume: maxVolume}
+	if err := s.CreateBucket(&b); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	return b
+}
+
+// mustCreateSizedFruit cria uma fruta solta com peso e volume.
+func mustCreateSizedFruit(t *testing.T, s Store, name string, weight, volume *float64) models.Fruit {
+	t.Helper()
+
+	f := models.Fruit{Name: name, Price: brl(100), ExpirationTime: time.Now().Add(time.Hour).Unix(), Weight: weight, Volume: volume}
+	if err := s.CreateFruit(&f); err != nil {
+		t.Fatalf("CreateFruit: %v", err)
+	}
+
+	return f
+}
+
+func testWeightAndVolumeLimits(t *testing.T, s Store) {
+	b := mustCreateLimitedBucket(t, s, 10, ptr(2), ptr(3))
+	other := mustCreateBucket(t, s, 10)
+
+	got, err := s.GetBucket(b.ID)
+	if err != nil {
+		t.Fatalf("GetBucket: %v", err)
+	}
+	if got.MaxWeight == nil || *got.MaxWeight != 2 || got.MaxVolume == nil || *got.MaxVolume != 3 {
+		t.Errorf("Expected limits 2 and 3. Got %v and %v", got.MaxWeight, got.MaxVolume)
+	}
+
+	heavy := mustCreateSizedFruit(t, s, "Melon", ptr(1.5), ptr(1))
+	if err := s.AddFruitToBucket(heavy.ID, b.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+
+	// Frutas sem peso ou volume contam como zero nessas dimensões.
+	plain := mustCreateFruit(t, s, "Apple", 100)
+	if err := s.AddFruitToBucket(plain.ID, b.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+
+	tooHeavy := mustCreateSizedFruit(t, s, "Pumpkin", ptr(1), nil)
+	err = s.AddFruitToBucket(tooHeavy.ID, b.ID)
+	if !errors.Is(err, models.ErrWeightLimitExceeded) || !errors.Is(err, models.ErrBucketFull) {
+		t.Errorf("Expected ErrWeightLimitExceeded. Got %v", err)
+	}
+
+	tooBig := mustCreateSizedFruit(t, s, "Pineapple", ptr(0.5), ptr(2.5))
+	if err := s.AddFruitToBucket(tooBig.ID, b.ID); !errors.Is(err, models.ErrVolumeLimitExceeded) {
+		t.Errorf("Expected ErrVolumeLimitExceeded. Got %v", err)
+	}
+
+	// As mesmas regras valem para as transferências.
+	if err := s.AddFruitToBucket(tooHeavy.ID, other.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+	if err := s.MoveFruit(tooHeavy.ID, other.ID, b.ID); !errors.Is(err, models.ErrWeightLimitExceeded) {
+		t.Errorf("Expected ErrWeightLimitExceeded on move. Got %v", err)
+	}
+
+	if err := s.MoveFruit(heavy.ID, b.ID, other.ID); err != nil {
+		t.Fatalf("MoveFruit: %v", err)
+	}
+	if err := s.MoveFruit(tooHeavy.ID, other.ID, b.ID); err != nil {
+		t.Errorf("Expected move to succeed after freeing weight. Got %v", err)
+	}
+}
+
+func testBatchesRespectWeightLimit(t *testing.T, s Store) {
+	b := mustCreateLimitedBucket(t, s, 10, ptr(2), nil)
+	f1 := mustCreateSizedFruit(t, s, "Apple", ptr(1), nil)
+	f2 := mustCreateSizedFruit(t, s, "Orange", ptr(1), nil)
+	f3 := mustCreateSizedFruit(t, s, "Pear", ptr(1), nil)
+
+	got, err := s.AddFruitsToBucket(b.ID, []int{f1.ID, f2.ID, f3.ID}, false)
+	if err != nil {
+		t.Fatalf("AddFruitsToBucket: %v", err)
+	}
+	want := []error{nil, nil, models.ErrWeightLimitExceeded}
+	for i := range want {
+		if !errors.Is(got[i], want[i]) {
+			t.Errorf("Item %d: Expected %v. Got %v", i, want[i], got[i])
+		}
+	}
+
+	// No lote de criação, a carga inclui as frutas anteriores do próprio lote.
+	c := mustCreateLimitedBucket(t, s, 10, nil, ptr(1))
+	fruits := []models.Fruit{batchFruit("Apple", c.ID), batchFruit("Orange", c.ID)}
+	fruits[0].Volume = ptr(0.6)
+	fruits[1].Volume = ptr(0.6)
+
+	errs, err := s.CreateFruits(fruits)
+	if err != nil {
+		t.Fatalf("CreateFruits: %v", err)
+	}
+	if errs[0] != nil || !errors.Is(errs[1], models.ErrVolumeLimitExceeded) {
+		t.Errorf("Expected only the second fruit to exceed the volume. Got %v", errs)
+	}
+	if fruits, _ := s.GetFruitsInBucket(c.ID); len(fruits) != 0 {
+		t.Errorf("Expected rejected batch to leave bucket empty. Got %+v", fruits)
+	}
+
+	moves := []models.FruitMove{{FruitID: f3.ID, FromBucketID: b.ID, ToBucketID: c.ID}}
+	if err := s.ApplyMoves(moves); !errors.Is(err, models.ErrFruitNotInBucket) {
+		t.Errorf("Expected ErrFruitNotInBucket. Got %v", err)
+	}
+
+	// Dentro de um plano, a carga liberada por um movimento vale para os seguintes.
+	d := mustCreateLimitedBucket(t, s, 10, ptr(1), nil)
+	moves = []models.FruitMove{
+		{FruitID: f1.ID, FromBucketID: b.ID, ToBucketID: d.ID},
+		{FruitID: f1.ID, FromBucketID: d.ID, ToBucketID: b.ID},
+		{FruitID: f2.ID, FromBucketID: b.ID, ToBucketID: d.ID},
+	}
+	if err := s.ApplyMoves(moves); err != nil {
+		t.Fatalf("ApplyMoves: %v", err)
+	}
+
+	moves = []models.FruitMove{{FruitID: f1.ID, FromBucketID: b.ID, ToBucketID: d.ID}}
+	if err := s.ApplyMoves(moves); !errors.Is(err, models.ErrWeightLimitExceeded) {
+		t.Errorf("Expected ErrWeightLimitExceeded. Got %v", err)
+	}
+}
+
+func testBucketDetailsDimensions(t *testing.T, s Store) {
+	b := mustCreateLimitedBucket(t, s, 4, ptr(10), ptr(4))
+	for _, f := range []models.Fruit{
+		mustCreateSizedFruit(t, s, "Melon", ptr(3), ptr(1)),
+		mustCreateSizedFruit(t, s, "Watermelon", ptr(4), ptr(2)),
+	} {
+		if err := s.AddFruitToBucket(f.ID, b.ID); err != nil {
+			t.Fatalf("AddFruitToBucket: %v", err)
+		}
+	}
+	unlimited := mustCreateBucket(t, s, 4)
+
+	details, err := s.ListBucketDetails(models.BucketFilter{SortBy: models.BucketSortID})
+	if err != nil {
+		t.Fatalf("ListBucketDetails: %v", err)
+	}
+	if len(details) != 2 {
+		t.Fatalf("Expected 2 buckets. Got %d", len(details))
+	}
+
+	// Quantidade 50%, peso 70% e volume 75%: o volume é a restrição.
+	d := details[0]
+	if d.TotalWeight != 7 || d.TotalVolume != 3 {
+		t.Errorf("Expected weight 7 and volume 3. Got %v and %v", d.TotalWeight, d.TotalVolume)
+	}
+	if d.WeightOccupancy == nil || *d.WeightOccupancy != 70 || d.VolumeOccupancy == nil || *d.VolumeOccupancy != 75 {
+		t.Errorf("Expected occupancies 70 and 75. Got %v and %v", d.WeightOccupancy, d.VolumeOccupancy)
+	}
+	if d.BindingConstraint != models.DimensionVolume {
+		t.Errorf("Expected binding constraint %q. Got %q", models.DimensionVolume, d.BindingConstraint)
+	}
+
+	d = details[1]
+	if d.ID != unlimited.ID || d.WeightOccupancy != nil || d.VolumeOccupancy != nil || d.BindingConstraint != models.DimensionCount {
+		t.Errorf("Expected unlimited bucket bound by count. Got %+v", d)
+	}
+}
+
+func testConcurrentBatchDepositsRespectCapacity(t *testing.T, s Store) {
+	const capacity, batches, batchSize = 7, 10, 3
+
+	b := mustCreateBucket(t, s, capacity)
+
+	errs := make([]error, batches)
+	results := make([][]error, batches)
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < batches; i++ {
+		fruitIDs := make([]int, batchSize)
+		for j := range fruitIDs {
+			fruitIDs[j] = mustCreateFruit(t, s, "Apple", 100).ID
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = s.AddFruitsToBucket(b.ID, fruitIDs, false)
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("AddFruitsToBucket: %v", errs[i])
+		}
+		for _, err := range results[i] {
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, models.ErrBucketFull):
+				t.Errorf("Expected ErrBucketFull. Got %v", err)
+			}
+		}
+	}
+
+	if succeeded != capacity {
+		t.Errorf("Expected %d successful deposits. Got %d", capacity, succeeded)
+	}
+
+	fruits, _ := s.GetFruitsInBucket(b.ID)
+	if len(fruits) != capacity {
+		t.Errorf("Expected bucket to hold %d fruits. Got %d", capacity, len(fruits))
+	}
+}
+
+func testMoveFruit(t *testing.T, s Store) {
+	ids := seedOccupancy(t, s, [][2]int{{2, 2}, {1, 0}, {1, 1}})
+	from, to, full := ids[0], ids[1], ids[2]
+
+	fruits, _ := s.GetFruitsInBucket(from)
+	moving, staying := fruits[0], fruits[1]
+	loose := mustCreateFruit(t, s, "Loose", 100)
+
+	tests := []struct {
+		name            string
+		fruit, from, to int
+		want            error
+	}{
+		{"missing target", moving.ID, from, to + 100, models.ErrBucketNotFound},
+		{"full target", moving.ID, from, full, models.ErrBucketFull},
+		{"missing fruit", moving.ID + 100, from, to, models.ErrFruitNotFound},
+		{"loose fruit", loose.ID, from, to, models.ErrFruitNotInBucket},
+		{"wrong source", moving.ID, full, to, models.ErrFruitNotInBucket},
+		// Em uma transferência, a fruta é verificada antes do destino.
+		{"missing fruit, full target", moving.ID + 100, from, full, models.ErrFruitNotFound},
+		{"loose fruit, full target", loose.ID, from, full, models.ErrFruitNotInBucket},
+	}
+
+	for _, tt := range tests {
+		if err := s.MoveFruit(tt.fruit, tt.from, tt.to); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Expected %v. Got %v", tt.name, tt.want, err)
+		}
+	}
+
+	missing := []models.FruitMove{{FruitID: moving.ID + 100, FromBucketID: from, ToBucketID: full}}
+	if err := s.ApplyMoves(missing); !errors.Is(err, models.ErrFruitNotFound) {
+		t.Errorf("ApplyMoves with a missing fruit: Expected %v. Got %v", models.ErrFruitNotFound, err)
+	}
+
+	if err := s.MoveFruit(moving.ID, from, to); err != nil {
+		t.Fatalf("MoveFruit: %v", err)
+	}
+
+	got, _ := s.GetFruit(moving.ID)
+	if !got.BucketID.Valid || int(got.BucketID.Int64) != to {
+		t.Errorf("Expected fruit in bucket %d. Got %+v", to, got.BucketID)
+	}
+
+	remaining, _ := s.GetFruitsInBucket(from)
+	if len(remaining) != 1 || remaining[0].ID != staying.ID {
+		t.Errorf("Expected only fruit %d left in source. Got %+v", staying.ID, remaining)
+	}
+}
+
+func testApplyMoves(t *testing.T, s Store) {
+	ids := seedOccupancy(t, s, [][2]int{{2, 2}, {1, 0}, {2, 0}})
+	a, b, c := ids[0], ids[1], ids[2]
+	fruits, _ := s.GetFruitsInBucket(a)
+
+	// A segunda fruta só cabe em b porque a primeira já saiu de lá.
+	moves := []models.FruitMove{
+		{FruitID: fruits[0].ID, FromBucketID: a, ToBucketID: b},
+		{FruitID: fruits[0].ID, FromBucketID: b, ToBucketID: c},
+		{FruitID: fruits[1].ID, FromBucketID: a, ToBucketID: b},
+	}
+	if err := s.ApplyMoves(moves); err != nil {
+		t.Fatalf("ApplyMoves: %v", err)
+	}
+
+	for bucket, want := range map[int][]int{a: {}, b: {fruits[1].ID}, c: {fruits[0].ID}} {
+		got, _ := s.GetFruitsInBucket(bucket)
+		if !sameIDs(fruitIDsOf(got), want) {
+			t.Errorf("Bucket %d: Expected fruits %v. Got %+v", bucket, want, got)
+		}
+	}
+}
+
+func testApplyMovesRollsBack(t *testing.T, s Store) {
+	ids := seedOccupancy(t, s, [][2]int{{2, 2}, {1, 0}})
+	a, b := ids[0], ids[1]
+	fruits, _ := s.GetFruitsInBucket(a)
+
+	tests := []struct {
+		name string
+		last models.FruitMove
+		want error
+	}{
+		{"full target", models.FruitMove{FruitID: fruits[1].ID, FromBucketID: a, ToBucketID: b}, models.ErrBucketFull},
+		{"missing target", models.FruitMove{FruitID: fruits[1].ID, FromBucketID: a, ToBucketID: b + 100}, models.ErrBucketNotFound},
+		{"missing fruit", models.FruitMove{FruitID: fruits[1].ID + 100, FromBucketID: a, ToBucketID: a}, models.ErrFruitNotFound},
+		{"wrong source", models.FruitMove{FruitID: fruits[0].ID, FromBucketID: a, ToBucketID: a}, models.ErrFruitNotInBucket},
+	}
+
+	for _, tt := range tests {
+		moves := []models.FruitMove{{FruitID: fruits[0].ID, FromBucketID: a, ToBucketID: b}, tt.last}
+		if err := s.ApplyMoves(moves); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Expected %v. Got %v", tt.name, tt.want, err)
+		}
+
+		got, _ := s.GetFruitsInBucket(a)
+		if len(got) != 2 {
+			t.Errorf("%s: Expected failed plan to leave source untouched. Got %+v", tt.name, got)
+		}
+	}
+}
+
+func testConcurrentMovesRespectCapacity(t *testing.T, s Store) {
+	const attempts = 20
+
+	ids := seedOccupancy(t, s, [][2]int{{attempts, attempts}, {3, 0}})
+	from, to := ids[0], ids[1]
+	fruits, _ := s.GetFruitsInBucket(from)
+
+	errs := make([]error, attempts)
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := range fruits {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = s.MoveFruit(fruits[i].ID, from, to)
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, models.ErrBucketFull):
+			t.Errorf("Expected ErrBucketFull. Got %v", err)
+		}
+	}
+
+	inTarget, _ := s.GetFruitsInBucket(to)
+	inSource, _ := s.GetFruitsInBucket(from)
+	if succeeded != 3 || len(inTarget) != 3 || len(inSource) != attempts-3 {
+		t.Errorf("Expected 3 moves. Got %d succeeded, %d in target, %d in source", succeeded, len(inTarget), len(inSource))
+	}
+}
+
+func testUpdateBucketCapacity(t *testing.T, s Store) {
+	ids := seedOccupancy(t, s, [][2]int{{4, 2}})
+
+	if _, err := s.UpdateBucket(ids[0], capacityUpdate(1)); !errors.Is(err, models.ErrCapacityBelowCount) {
+		t.Errorf("Expected ErrCapacityBelowCount. Got %v", err)
+	}
+
+	b, err := s.UpdateBucket(ids[0], capacityUpdate(2))
+	if err != nil {
+		t.Fatalf("UpdateBucket: %v", err)
+	}
+	if b.ID != ids[0] || b.Capacity != 2 {
+		t.Errorf("Expected bucket %d with capacity 2. Got %+v", ids[0], b)
+	}
+
+	got, _ := s.GetBucket(ids[0])
+	if got.Capacity != 2 {
+		t.Errorf("Expected stored capacity 2. Got %d", got.Capacity)
+	}
+
+	if _, err := s.UpdateBucket(ids[0]+100, capacityUpdate(5)); !errors.Is(err, models.ErrBucketNotFound) {
+		t.Errorf("Expected ErrBucketNotFound. Got %v", err)
+	}
+}
+
+// capacityUpdate monta uma alteração apenas da capacidade.
+func capacityUpdate(capacity int) models.UpdateBucketRequest {
+	return models.UpdateBucketRequest{Capacity: &capacity}
+}
+
+func testUpdateBucketExpiryWarning(t *testing.T, s Store) {
+	warning := int64(3600)
+	b := models.Bucket{Capacity: 2, ExpiryWarningSeconds: &warning}
+	if err := s.CreateBucket(&b); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	got, _ := s.GetBucket(b.ID)
+	if got.ExpiryWarningSeconds == nil || *got.ExpiryWarningSeconds != 3600 {
+		t.Errorf("Expected stored warning 3600. Got %v", got.ExpiryWarningSeconds)
+	}
+
+	warning = 60
+	updated, err := s.UpdateBucket(b.ID, models.UpdateBucketRequest{ExpiryWarningSeconds: &warning})
+	if err != nil {
+		t.Fatalf("UpdateBucket: %v", err)
+	}
+	if updated.ExpiryWarningSeconds == nil || *updated.ExpiryWarningSeconds != 60 || updated.Capacity != 2 {
+		t.Errorf("Expected bucket with warning 60. Got %+v", updated)
+	}
+
+	details, _ := s.ListBucketDetails(models.BucketFilter{SortBy: models.BucketSortID})
+	if len(details) != 1 || details[0].ExpiryWarningSeconds == nil || *details[0].ExpiryWarningSeconds != 60 {
+		t.Errorf("Expected details with warning 60. Got %+v", details)
+	}
+
+	// Sem o campo, o aviso não muda; zero o desliga.
+	if updated, err = s.UpdateBucket(b.ID, capacityUpdate(3)); err != nil || updated.ExpiryWarningSeconds == nil || *updated.ExpiryWarningSeconds != 60 {
+		t.Errorf("Expected the warning to be kept. Got %+v (%v)", updated, err)
+	}
+
+	off := int64(0)
+	if updated, err = s.UpdateBucket(b.ID, models.UpdateBucketRequest{ExpiryWarningSeconds: &off}); err != nil || updated.ExpiryWarningSeconds != nil {
+		t.Errorf("Expected the warning to be cleared. Got %+v (%v)", updated, err)
+	}
+
+	if _, err := s.UpdateBucket(b.ID+100, models.UpdateBucketRequest{ExpiryWarningSeconds: &warning}); !errors.Is(err, models.ErrBucketNotFound) {
+		t.Errorf("Expected ErrBucketNotFound. Got %v", err)
+	}
+}
+
+// testUpdateBucketAtomic verifica que uma alteração recusada não aplica
+// nenhum dos campos.
+func testUpdateBucketAtomic(t *testing.T, s Store) {
+	ids := seedOccupancy(t, s, [][2]int{{4, 2}})
+
+	capacity, warning := 1, int64(600)
+	_, err := s.UpdateBucket(ids[0], models.UpdateBucketRequest{Capacity: &capacity, ExpiryWarningSeconds: &warning})
+	if !errors.Is(err, models.ErrCapacityBelowCount) {
+		t.Fatalf("Expected ErrCapacityBelowCount. Got %v", err)
+	}
+
+	got, _ := s.GetBucket(ids[0])
+	if got.Capacity != 4 || got.ExpiryWarningSeconds != nil {
+		t.Errorf("Expected the bucket to be unchanged. Got %+v", got)
+	}
+
+	capacity = 3
+	got, err = s.UpdateBucket(ids[0], models.UpdateBucketRequest{Capacity: &capacity, ExpiryWarningSeconds: &warning})
+	if err != nil || got.Capacity != 3 || got.ExpiryWarningSeconds == nil || *got.ExpiryWarningSeconds != 600 {
+		t.Errorf("Expected both fields to be updated. Got %+v (%v)", got, err)
+	}
+}
+
+func testUpdateFruit(t *testing.T, s Store) {
+	f := mustCreateFruit(t, s, "Aple", 100)
+
+	price := brl(275)
+	got, err := s.UpdateFruit(f.ID, models.UpdateFruitRequest{Price: &price, ExtendExpirationSeconds: 60})
+	if err != nil {
+		t.Fatalf("UpdateFruit: %v", err)
+	}
+	if got.Name != "Aple" || got.Price != brl(275) || got.ExpirationTime != f.ExpirationTime+60 {
+		t.Errorf("Expected only price and expiration to change. Got %+v", got)
+	}
+
+	name := "Apple"
+	got, err = s.UpdateFruit(f.ID, models.UpdateFruitRequest{Name: &name})
+	if err != nil {
+		t.Fatalf("UpdateFruit: %v", err)
+	}
+	if got.Name != "Apple" || got.Price != brl(275) {
+		t.Errorf("Expected name to change and price to be kept. Got %+v", got)
+	}
+
+	stored, _ := s.GetFruit(f.ID)
+	if stored != got {
+		t.Errorf("Expected stored fruit %+v. Got %+v", got, stored)
+	}
+
+	if _, err := s.UpdateFruit(f.ID+100, models.UpdateFruitRequest{Name: &name}); !errors.Is(err, models.ErrFruitNotFound) {
+		t.Errorf("Expected ErrFruitNotFound. Got %v", err)
+	}
+
+	// Uma extensão que estouraria a expiração é recusada sem alterar nada.
+	other := "Pear"
+	if _, err := s.UpdateFruit(f.ID, models.UpdateFruitRequest{Name: &other, ExtendExpirationSeconds: math.MaxInt64 - 1000}); !errors.Is(err, models.ErrExpirationTooFar) {
+		t.Errorf("Expected ErrExpirationTooFar. Got %v", err)
+	}
+	if stored, err := s.GetFruit(f.ID); err != nil || stored != got {
+		t.Errorf("Expected fruit to be unchanged %+v. Got %+v (%v)", got, stored, err)
+	}
+	if _, err := s.UpdateFruit(f.ID+100, models.UpdateFruitRequest{ExtendExpirationSeconds: math.MaxInt64 - 1000}); !errors.Is(err, models.ErrFruitNotFound) {
+		t.Errorf("Expected ErrFruitNotFound for a missing fruit. Got %v", err)
+	}
+}
+
+func testRemoveFruitFromBucket(t *testing.T, s Store) {
+	b := mustCreateBucket(t, s, 1)
+	f := mustCreateFruit(t, s, "Apple", 100)
+	if err := s.AddFruitToBucket(f.ID, b.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+
+	if n, err := s.RemoveFruitFromBucket(f.ID, b.ID+100); err != nil || n != 0 {
+		t.Errorf("Expected no rows affected for wrong bucket. Got %d, %v", n, err)
+	}
+
+	n, err := s.RemoveFruitFromBucket(f.ID, b.ID)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 row affected. Got %d, %v", n, err)
+	}
+
+	got, _ := s.GetFruit(f.ID)
+	if got.BucketID.Valid {
+		t.Errorf("Expected fruit to be loose. Got %+v", got.BucketID)
+	}
+}
+
+func testDeleteBucketReleasesFruits(t *testing.T, s Store) {
+	b := mustCreateBucket(t, s, 1)
+	f := mustCreateFruit(t, s, "Apple", 100)
+	if err := s.AddFruitToBucket(f.ID, b.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+
+	if err := s.DeleteBucket(b.ID); err != nil {
+		t.Fatalf("DeleteBucket: %v", err)
+	}
+
+	if _, err := s.GetBucket(b.ID); !errors.Is(err, models.ErrBucketNotFound) {
+		t.Errorf("Expected ErrBucketNotFound. Got %v", err)
+	}
+
+	got, err := s.GetFruit(f.ID)
+	if err != nil {
+		t.Fatalf("GetFruit: %v", err)
+	}
+	if got.BucketID.Valid {
+		t.Errorf("Expected bucket_id to be NULL. Got %+v", got.BucketID)
+	}
+}
+
+func testDeleteFruit(t *testing.T, s Store) {
+	f := mustCreateFruit(t, s, "Apple", 100)
+
+	if err := s.DeleteFruit(f.ID); err != nil {
+		t.Fatalf("DeleteFruit: %v", err)
+	}
+	if _, err := s.GetFruit(f.ID); !errors.Is(err, models.ErrFruitNotFound) {
+		t.Errorf("Expected ErrFruitNotFound. Got %v", err)
+	}
+}
+
+func testExpireFruitsArchives(t *testing.T, s Store) {
+	now := time.Now().Unix()
+
+	b := mustCreateBucket(t, s, 2)
+	expired := models.Fruit{Name: "Old", Price: brl(250), ExpirationTime: now - 10}
+	fresh := models.Fruit{Name: "New", Price: brl(100), ExpirationTime: now + 3600}
+	for _, f := range []*models.Fruit{&expired, &fresh} {
+		if err := s.CreateFruit(f); err != nil {
+			t.Fatalf("CreateFruit: %v", err)
+		}
+	}
+	if err := s.AddFruitToBucket(expired.ID, b.ID); err != nil {
+		t.Fatalf("AddFruitToBucket: %v", err)
+	}
+
+	expiredNow, err := s.ExpireFruits(now)
+	if err != nil {
+		t.Fatalf("ExpireFruits: %v", err)
+	}
+	if len(expiredNow) != 1 || expiredNow[0].ID != expired.ID || expiredNow[0].ExpiredAt != now {
+		t.Errorf("Expected fruit %d archived at %d. Got %+v", expired.ID, now, expiredNow)
+	}
+
+	if _, err := s.GetFruit(expired.ID); !errors.Is(err, models.ErrFruitNotFound) {
+		t.Errorf("Expected expired fruit to leave the active fruits. Got %v", err)
+	}
+	if _, err := s.GetFruit(fresh.ID); err != nil {
+		t.Errorf("Expected fresh fruit to be kept. Got %v", err)
+	}
+
+	archived, err := s.ListExpiredFruits(0, 0)
+	if err != nil {
+		t.Fatalf("ListExpiredFruits: %v", err)
+	}
+	if len(archived) != 1 {
+		t.Fatalf("Expected 1 archived fruit. Got %d", len(archived))
+	}
+
+	got := archived[0]
+	if got.ID != expired.ID || got.Price != brl(250) || got.ExpiredAt != now {
+		t.Errorf("Expected archived copy of %+v expired at %d. Got %+v", expired, now, got)
+	}
+	if !got.BucketID.Valid || int(got.BucketID.Int64) != b.ID {
+		t.Errorf("Expected archived fruit to keep bucket %d. Got %+v", b.ID, got.BucketID)
+	}
+
+	// Uma nova varredura não deve arquivar nada de novo
+	if again, err := s.ExpireFruits(now); err != nil || len(again) != 0 {
+		t.Errorf("Expected nothing to archive. Got %+v, %v", again, err)
+	}
+}
+
+func testListExpiredFruitsByRange(t *testing.T, s Store) {
+	now := time.Now().Unix()
+
+	for _, offset := range []int64{-300, -200, -100} {
+		f := models.Fruit{Name: "Old", Price: brl(100), ExpirationTime: now + offset}
+		if err := s.CreateFruit(&f); err != nil {
+			t.Fatalf("CreateFruit: %v", err)
+		}
+	}
+
+	if _, err := s.ExpireFruits(now); err != nil {
+		t.Fatalf("ExpireFruits: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to int64
+		want     int
+	}{
+		{"unbounded", 0, 0, 3},
+		{"from", now - 200, 0, 2},
+		{"to", 0, now - 200, 2},
+		{"between", now - 250, now - 150, 1},
+		{"empty", now, 0, 0},
+	}
+
+	for _, tt := range tests {
+		fruits, err := s.ListExpiredFruits(tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("%s: ListExpiredFruits: %v", tt.name, err)
+		}
+		if len(fruits) != tt.want {
+			t.Errorf("%s: Expected %d fruits. Got %d", tt.name, tt.want, len(fruits))
+		}
+	}
+}
+
+func testWebhooks(t *testing.T, s Store) {
+	w := models.Webhook{URL: "http://example.com/hook", Events: []string{models.EventFruitCreated, models.EventBucketFull}, Secret: "s3cret", CreatedAt: 100}
+	if err := s.CreateWebhook(&w); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	if w.ID == 0 {
+		t.Fatalf("Expected an ID to be assigned")
+	}
+
+	got, err := s.GetWebhook(w.ID)
+	if err != nil {
+		t.Fatalf("GetWebhook: %v", err)
+	}
+	if got.URL != w.URL || got.Secret != "s3cret" || got.CreatedAt != 100 || len(got.Events) != 2 || !got.Subscribed(models.EventBucketFull) {
+		t.Errorf("Expected %+v. Got %+v", w, got)
+	}
+
+	other := models.Webhook{URL: "http://example.com/other", Events: []string{models.EventFruitExpired}, Secret: "x"}
+	if err := s.CreateWebhook(&other); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	webhooks, _ := s.ListWebhooks()
+	if len(webhooks) != 2 || webhooks[0].ID != w.ID || webhooks[1].ID != other.ID {
+		t.Errorf("Expected webhooks [%d %d]. Got %+v", w.ID, other.ID, webhooks)
+	}
+
+	if err := s.DeleteWebhook(w.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if _, err := s.GetWebhook(w.ID); !errors.Is(err, models.ErrWebhookNotFound) {
+		t.Errorf("Expected ErrWebhookNotFound. Got %v", err)
+	}
+	if err := s.DeleteWebhook(w.ID); !errors.Is(err, models.ErrWebhookNotFound) {
+		t.Errorf("Expected ErrWebhookNotFound on second delete. Got %v", err)
+	}
+}
+
+func testWebhookDeliveries(t *testing.T, s Store) {
+	w := models.Webhook{URL: "http://example.com/hook", Events: []string{models.EventFruitCreated}, Secret: "s3cret"}
+	if err := s.CreateWebhook(&w); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	var ids []int
+	for i := 0; i < 2; i++ {
+		d := models.WebhookDelivery{WebhookID: w.ID, Event: models.EventFruitCreated, Payload: []byte(`{"id":1}`), Status: models.DeliveryPending, CreatedAt: 100, UpdatedAt: 100}
+		if err := s.CreateDelivery(&d); err != nil {
+			t.Fatalf("CreateDelivery: %v", err)
+		}
+		ids = append(ids, d.ID)
+	}
+
+	orphan := models.WebhookDelivery{WebhookID: w.ID + 100, Event: models.EventFruitCreated, Payload: []byte(`{}`), Status: models.DeliveryPending}
+	if err := s.CreateDelivery(&orphan); !errors.Is(err, models.ErrWebhookNotFound) {
+		t.Errorf("Expected ErrWebhookNotFound. Got %v", err)
+	}
+
+	status := 200
+	done := models.WebhookDelivery{ID: ids[0], Status: models.DeliveryDelivered, Attempts: 2, LastStatusCode: &status, UpdatedAt: 160}
+	if err := s.UpdateDelivery(done); err != nil {
+		t.Fatalf("UpdateDelivery: %v", err)
+	}
+
+	deliveries, _ := s.ListDeliveries(w.ID)
+	if len(deliveries) != 2 || deliveries[0].ID != ids[1] || deliveries[1].ID != ids[0] {
+		t.Fatalf("Expected deliveries [%d %d]. Got %+v", ids[1], ids[0], deliveries)
+	}
+
+	got := deliveries[1]
+	if got.Status != models.DeliveryDelivered || got.Attempts != 2 || got.LastStatusCode == nil || *got.LastStatusCode != 200 ||
+		got.UpdatedAt != 160 || got.CreatedAt != 100 || got.Event != models.EventFruitCreated || string(got.Payload) != `{"id":1}` {
+		t.Errorf("Unexpected updated delivery %+v", got)
+	}
+
+	pending, _ := s.ListPendingDeliveries()
+	if len(pending) != 1 || pending[0].ID != ids[1] {
+		t.Errorf("Expected pending delivery %d. Got %+v", ids[1], pending)
+	}
+
+	if err := s.UpdateDelivery(models.WebhookDelivery{ID: ids[1] + 100}); !errors.Is(err, models.ErrDeliveryNotFound) {
+		t.Errorf("Expected ErrDeliveryNotFound. Got %v", err)
+	}
+
+	// Excluir o webhook leva junto o registro das entregas.
+	if err := s.DeleteWebhook(w.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if deliveries, _ := s.ListDeliveries(w.ID); len(deliveries) != 0 {
+		t.Errorf("Expected deliveries to be deleted. Got %+v", deliveries)
+	}
+	if pending, _ := s.ListPendingDeliveries(); len(pending) != 0 {
+		t.Errorf("Expected no pending deliveries. Got %+v", pending)
+	}
+}
@@ -0,0 +1,203 @@
+// Package expiration arquiva as frutas no instante em que expiram, mantendo
+// uma fila de prioridade com os próximos vencimentos em vez de consultar o
+// banco periodicamente.
+package expiration
+
+import (
+	"container/heap"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// Scheduler mantém um min-heap com a expiração de cada fruta e acorda
+// exatamente quando a próxima vence.
+type Scheduler struct {
+	fruits models.FruitStore
+
+	// maxWait limita o tempo entre duas varreduras, cobrindo frutas
+	// alteradas no banco por fora deste processo.
+	maxWait time.Duration
+
+	mu    sync.Mutex
+	queue queue
+	items map[int]*item
+
+	// wake é sinalizado quando o heap muda e o timer precisa ser recalculado.
+	wake chan struct{}
+
+	// OnExpire, se definido, recebe as frutas arquivadas em cada varredura.
+	// É chamado pela goroutine de Run e não deve bloquear.
+	OnExpire func(fruits []models.ExpiredFruit)
+}
+
+// NewScheduler cria um agendador sobre o store de frutas. maxWait é o
+// intervalo máximo entre duas varreduras, mesmo sem vencimentos conhecidos.
+func NewScheduler(fruits models.FruitStore, maxWait time.Duration) *Scheduler {
+	return &Scheduler{
+		fruits:  fruits,
+		maxWait: maxWait,
+		items:   make(map[int]*item),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Load carrega a expiração de todas as frutas do store, substituindo o que
+// estiver agendado.
+func (s *Scheduler) Load() error {
+	fruits, err := s.fruits.ListFruits(models.FruitFilter{})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.queue = s.queue[:0]
+	s.items = make(map[int]*item, len(fruits))
+	for _, f := range fruits {
+		it := &item{fruitID: f.ID, expiresAt: f.ExpirationTime, index: len(s.queue)}
+		s.queue = append(s.queue, it)
+		s.items[f.ID] = it
+	}
+	heap.Init(&s.queue)
+	s.mu.Unlock()
+
+	s.notify()
+
+	return nil
+}
+
+// Schedule agenda (ou reagenda) a expiração de uma fruta.
+func (s *Scheduler) Schedule(fruitID int, expiresAt int64) {
+	s.mu.Lock()
+	if it, ok := s.items[fruitID]; ok {
+		it.expiresAt = expiresAt
+		heap.Fix(&s.queue, it.index)
+	} else {
+		it := &item{fruitID: fruitID, expiresAt: expiresAt}
+		heap.Push(&s.queue, it)
+		s.items[fruitID] = it
+	}
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Cancel remove a fruta da fila, por exemplo quando ela é excluída.
+func (s *Scheduler) Cancel(fruitID int) {
+	s.mu.Lock()
+	if it, ok := s.items[fruitID]; ok {
+		heap.Remove(&s.queue, it.index)
+		delete(s.items, fruitID)
+	}
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Run processa os vencimentos até que o contexto seja cancelado. Uma
+// varredura em andamento é concluída antes do retorno.
+func (s *Scheduler) Run(ctx context.Context) {
+	timer := time.NewTimer(s.nextWait(time.Now()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+			s.sweep(time.Now())
+		}
+
+		timer.Reset(s.nextWait(time.Now()))
+	}
+}
+
+// sweep arquiva no store as frutas vencidas até `now` e as retira da fila.
+func (s *Scheduler) sweep(now time.Time) {
+	archived, err := s.fruits.ExpireFruits(now.Unix())
+	if err != nil {
+		log.Println("Erro ao arquivar frutas expiradas:", err)
+		return
+	}
+
+	if len(archived) > 0 {
+		log.Println(len(archived), "Fruta(s) expirada(s) arquivada(s).")
+		if s.OnExpire != nil {
+			s.OnExpire(archived)
+		}
+	}
+
+	s.mu.Lock()
+	for len(s.queue) > 0 && s.queue[0].expiresAt <= now.Unix() {
+		it := heap.Pop(&s.queue).(*item)
+		delete(s.items, it.fruitID)
+	}
+	s.mu.Unlock()
+}
+
+// nextWait calcula quanto falta para a próxima expiração, limitado a maxWait.
+func (s *Scheduler) nextWait(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		return s.maxWait
+	}
+
+	wait := time.Unix(s.queue[0].expiresAt, 0).Sub(now)
+	if wait < 0 {
+		return 0
+	}
+	if wait > s.maxWait {
+		return s.maxWait
+	}
+
+	return wait
+}
+
+// notify acorda o loop de Run sem bloquear caso ele já tenha sido avisado.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// item é uma entrada do heap de expirações.
+type item struct {
+	fruitID   int
+	expiresAt int64
+	index     int
+}
+
+// queue implementa heap.Interface ordenando pela expiração mais próxima.
+type queue []*item
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool { return q[i].expiresAt < q[j].expiresAt }
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x any) {
+	it := x.(*item)
+	it.index = len(*q)
+	*q = append(*q, it)
+}
+
+func (q *queue) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+
+	return it
+}
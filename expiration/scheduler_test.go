@@ -0,0 +1,150 @@
+package expiration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mr-utzig/planne-test/database/memory"
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// waitUntil aguarda a condição ser satisfeita ou falha após o timeout.
+func waitUntil(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return cond()
+}
+
+// startScheduler executa o agendador até o fim do teste.
+func startScheduler(t *testing.T, s *Scheduler) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// TestSchedulerExpiresAtDeadline verifica que a fruta é removida quando vence,
+// sem depender do intervalo máximo entre varreduras.
+func TestSchedulerExpiresAtDeadline(t *testing.T) {
+	store := memory.New()
+	fruit := models.Fruit{Name: "Apple", Price: models.Money{Amount: 100, Currency: "BRL"}, ExpirationTime: time.Now().Unix() + 1}
+	store.CreateFruit(&fruit)
+
+	scheduler := NewScheduler(store, time.Hour)
+	if err := scheduler.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	startScheduler(t, scheduler)
+
+	if _, err := store.GetFruit(fruit.ID); err != nil {
+		t.Fatalf("Expected fruit to exist before expiring. Got %v", err)
+	}
+
+	removed := waitUntil(t, 2*time.Second, func() bool {
+		_, err := store.GetFruit(fruit.ID)
+		return err != nil
+	})
+	if !removed {
+		t.Errorf("Expected fruit to be removed at its expiration time")
+	}
+}
+
+// TestSchedulerWakesOnSchedule verifica que frutas agendadas depois do início
+// acordam o loop imediatamente.
+func TestSchedulerWakesOnSchedule(t *testing.T) {
+	store := memory.New()
+	scheduler := NewScheduler(store, time.Hour)
+	startScheduler(t, scheduler)
+
+	fruit := models.Fruit{Name: "Apple", Price: models.Money{Amount: 100, Currency: "BRL"}, ExpirationTime: time.Now().Unix() - 1}
+	store.CreateFruit(&fruit)
+	scheduler.Schedule(fruit.ID, fruit.ExpirationTime)
+
+	removed := waitUntil(t, time.Second, func() bool {
+		_, err := store.GetFruit(fruit.ID)
+		return err != nil
+	})
+	if !removed {
+		t.Errorf("Expected scheduled fruit to be removed")
+	}
+}
+
+// TestSchedulerOnExpire verifica que as frutas arquivadas em cada varredura
+// são repassadas a OnExpire.
+func TestSchedulerOnExpire(t *testing.T) {
+	store := memory.New()
+	fruit := models.Fruit{Name: "Apple", Price: models.Money{Amount: 100, Currency: "BRL"}, ExpirationTime: time.Now().Unix() - 1}
+	store.CreateFruit(&fruit)
+
+	expired := make(chan []models.ExpiredFruit, 1)
+	scheduler := NewScheduler(store, time.Hour)
+	scheduler.OnExpire = func(fruits []models.ExpiredFruit) { expired <- fruits }
+	if err := scheduler.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	startScheduler(t, scheduler)
+
+	select {
+	case fruits := <-expired:
+		if len(fruits) != 1 || fruits[0].ID != fruit.ID {
+			t.Errorf("Expected fruit %d. Got %+v", fruit.ID, fruits)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected OnExpire to be called")
+	}
+}
+
+// TestSchedulerCancel verifica que frutas canceladas saem da fila.
+func TestSchedulerCancel(t *testing.T) {
+	scheduler := NewScheduler(memory.New(), time.Hour)
+
+	now := time.Now()
+	scheduler.Schedule(1, now.Add(time.Minute).Unix())
+	scheduler.Schedule(2, now.Add(time.Hour).Unix())
+	scheduler.Cancel(1)
+
+	if wait := scheduler.nextWait(now); wait < 59*time.Minute {
+		t.Errorf("Expected next wake to follow fruit 2. Got %s", wait)
+	}
+
+	scheduler.Cancel(2)
+	if wait := scheduler.nextWait(now); wait != time.Hour {
+		t.Errorf("Expected max wait with an empty queue. Got %s", wait)
+	}
+}
+
+// TestSchedulerStopsOnCancel verifica que Run retorna quando o contexto é cancelado.
+func TestSchedulerStopsOnCancel(t *testing.T) {
+	scheduler := NewScheduler(memory.New(), time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Expected scheduler to stop after context cancellation")
+	}
+}
@@ -5,4 +5,5 @@ go 1.24.0
 require (
 	github.com/go-chi/chi/v5 v5.2.2
 	github.com/mattn/go-sqlite3 v1.14.31
+	gopkg.in/yaml.v3 v3.0.1
 )
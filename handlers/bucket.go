@@ -1,18 +1,23 @@
 package handlers
 
 import (
-	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/mr-utzig/planne-test/models"
+	"github.com/mr-utzig/planne-test/rebalance"
 )
 
 // CreateBucket cria um novo balde.
-func CreateBucket(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateBucket(w http.ResponseWriter, r *http.Request) {
 	var bucket models.Bucket
 	if err := json.NewDecoder(r.Body).Decode(&bucket); err != nil {
 		respondWithError(w, http.StatusBadRequest, "Payload inválido")
@@ -24,7 +29,17 @@ func CreateBucket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := bucket.Insert(); err != nil {
+	if !positiveOrNil(bucket.MaxWeight) || !positiveOrNil(bucket.MaxVolume) {
+		respondWithError(w, http.StatusBadRequest, "Campos 'max_weight' e 'max_volume' devem ser positivos quando informados")
+		return
+	}
+
+	if bucket.ExpiryWarningSeconds != nil && *bucket.ExpiryWarningSeconds <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Campo 'expiry_warning_seconds' deve ser positivo quando informado")
+		return
+	}
+
+	if err := s.Buckets.CreateBucket(&bucket); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Erro ao criar o balde")
 		return
 	}
@@ -33,14 +48,14 @@ func CreateBucket(w http.ResponseWriter, r *http.Request) {
 }
 
 // DeleteBucket exclui um balde, se ele estiver vazio.
-func DeleteBucket(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DeleteBucket(w http.ResponseWriter, r *http.Request) {
 	bucketID, err := strconv.Atoi(chi.URLParam(r, "bucketID"))
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "ID de balde inválido")
 		return
 	}
 
-	fruitsInBucket, err := models.Fruit{}.GetFruitsInBucket(bucketID)
+	fruitsInBucket, err := s.Fruits.GetFruitsInBucket(bucketID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Erro ao verificar o balde")
 		return
@@ -51,124 +66,416 @@ func DeleteBucket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = models.Bucket{}.DeleteByID(bucketID)
+	// A exclusão de um balde inexistente continua respondendo 204, mas só
+	// gera evento se havia um balde.
+	bucket, getErr := s.Buckets.GetBucket(bucketID)
+	if getErr != nil && !errors.Is(getErr, models.ErrNotFound) {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao verificar o balde")
+		return
+	}
+
+	err = s.Buckets.DeleteBucket(bucketID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Erro ao excluir o balde")
 		return
 	}
 
+	if getErr == nil {
+		s.Events.Publish(models.EventBucketDeleted, bucket)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// ListBuckets lista todos os baldes com detalhes, ordenados por ocupação.
-func ListBuckets(w http.ResponseWriter, r *http.Request) {
-	buckets, err := models.Bucket{}.GetAll()
+// ListBuckets lista os baldes com detalhes, paginados por cursor. Por padrão
+// a ordenação é pela ocupação em ordem decrescente.
+func (s *Server) ListBuckets(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseBucketFilter(r)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			respondWithError(w, http.StatusNotFound, "Nenhum balde encontrado")
-			return
-		}
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Busca um balde a mais para saber se existe uma próxima página
+	limit := filter.Limit
+	filter.Limit++
 
+	allBucketsDetails, err := s.Buckets.ListBucketDetails(filter)
+	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar baldes")
 		return
 	}
 
-	var allBucketsDetails []models.BucketDetails
-	for _, bucket := range buckets {
-		fruitsInBucket, err := models.Fruit{}.GetFruitsInBucket(bucket.ID)
-		if err != nil && err != sql.ErrNoRows {
-			respondWithError(w, http.StatusInternalServerError, "Erro ao buscar frutas do balde")
-			return
+	now := time.Now()
+	for i := range allBucketsDetails {
+		s.Markdown.PriceBucket(&allBucketsDetails[i], now)
+		allBucketsDetails[i].CalcExpiring(now)
+	}
+
+	page := models.BucketPage{Buckets: allBucketsDetails}
+	if len(allBucketsDetails) > limit {
+		page.Buckets = allBucketsDetails[:limit]
+		next := encodeBucketCursor(filter, page.Buckets[limit-1].Cursor(filter))
+		page.NextCursor = &next
+	}
+
+	if page.Buckets == nil {
+		page.Buckets = []models.BucketDetails{}
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
+
+// bucketCursor é o conteúdo serializado do cursor da listagem de baldes. A
+// ordenação (e a moeda, quando ela importa) é guardada para rejeitar cursores
+// usados com outra ordenação.
+type bucketCursor struct {
+	Sort     string  `json:"sort"`
+	Desc     bool    `json:"desc"`
+	Currency string  `json:"currency,omitempty"`
+	Value    float64 `json:"value"`
+	ID       int     `json:"id"`
+}
+
+// encodeBucketCursor serializa o cursor como JSON em base64 para URLs.
+func encodeBucketCursor(filter models.BucketFilter, cursor models.BucketCursor) string {
+	data, _ := json.Marshal(bucketCursor{Sort: filter.SortBy, Desc: filter.Desc, Currency: filter.Currency, Value: cursor.Value, ID: cursor.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeBucketCursor valida o cursor recebido contra a ordenação pedida.
+func decodeBucketCursor(value string, filter models.BucketFilter) (*models.BucketCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var c bucketCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+
+	if c.Sort != filter.SortBy || c.Desc != filter.Desc || c.Currency != filter.Currency || c.ID <= 0 {
+		return nil, errors.New("cursor não corresponde à ordenação")
+	}
+
+	return &models.BucketCursor{Value: c.Value, ID: c.ID}, nil
+}
+
+// parseBucketFilter lê os parâmetros da listagem de baldes. Os erros
+// devolvidos já estão prontos para serem enviados ao cliente.
+func parseBucketFilter(r *http.Request) (models.BucketFilter, error) {
+	query := r.URL.Query()
+	filter := models.BucketFilter{
+		SortBy: models.BucketSortOccupancy,
+		Desc:   true,
+		Limit:  defaultPageSize,
+	}
+
+	switch v := query.Get("sort"); v {
+	case "":
+	case models.BucketSortOccupancy, models.BucketSortTotalValue, models.BucketSortCapacity, models.BucketSortID:
+		filter.SortBy = v
+	default:
+		return filter, errors.New("Parâmetro 'sort' deve ser occupancy, total_value, capacity ou id")
+	}
+
+	// A moeda só muda a ordenação por valor; nas demais é ignorada.
+	if v := query.Get("currency"); v != "" && filter.SortBy == models.BucketSortTotalValue {
+		if !models.ValidCurrency(v) {
+			return filter, errors.New("Parâmetro 'currency' deve ser uma moeda aceita: " + strings.Join(models.Currencies(), ", "))
 		}
+		filter.Currency = v
+	}
 
-		bucketDetails := models.BucketDetails{
-			ID:       bucket.ID,
-			Capacity: bucket.Capacity,
-			Fruits:   fruitsInBucket,
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Desc = false
+	default:
+		return filter, errors.New("Parâmetro 'order' deve ser asc ou desc")
+	}
+
+	if v := query.Get("min_occupancy"); v != "" {
+		minOccupancy, err := strconv.ParseFloat(v, 64)
+		if err != nil || minOccupancy < 0 || minOccupancy > 100 {
+			return filter, errors.New("Parâmetro 'min_occupancy' deve estar entre 0 e 100")
 		}
+		filter.MinOccupancy = minOccupancy
+	}
 
-		bucketDetails.CalcTotalValue()
-		bucketDetails.CalcOccupancyPercentage()
+	for name, target := range map[string]**bool{"empty": &filter.Empty, "full": &filter.Full} {
+		if v := query.Get(name); v != "" {
+			value, err := strconv.ParseBool(v)
+			if err != nil {
+				return filter, fmt.Errorf("Parâmetro '%s' deve ser true ou false", name)
+			}
+			*target = &value
+		}
+	}
 
-		allBucketsDetails = append(allBucketsDetails, bucketDetails)
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return filter, fmt.Errorf("Parâmetro 'limit' deve estar entre 1 e %d", maxPageSize)
+		}
+		filter.Limit = limit
 	}
 
-	// Ordena os baldes pela ocupação em ordem decrescente
-	sort.Slice(allBucketsDetails, func(i, j int) bool {
-		return allBucketsDetails[i].Occupancy > allBucketsDetails[j].Occupancy
-	})
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := decodeBucketCursor(v, filter)
+		if err != nil {
+			return filter, errors.New("Parâmetro 'cursor' inválido")
+		}
+		filter.After = cursor
+	}
 
-	respondWithJSON(w, http.StatusOK, allBucketsDetails)
+	return filter, nil
 }
 
-// DepositFruit deposita uma fruta em um balde.
-func DepositFruit(w http.ResponseWriter, r *http.Request) {
+// GetBucket retorna um balde com suas frutas, valor total e ocupação.
+func (s *Server) GetBucket(w http.ResponseWriter, r *http.Request) {
 	bucketID, err := strconv.Atoi(chi.URLParam(r, "bucketID"))
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "ID de balde inválido")
 		return
 	}
 
-	var payload struct {
-		FruitID int `json:"fruit_id"`
+	bucket, err := s.Buckets.GetBucket(bucketID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Balde não encontrado")
+			return
+		}
+
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar o balde")
+		return
+	}
+
+	fruitsInBucket, err := s.Fruits.GetFruitsInBucket(bucket.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar frutas do balde")
+		return
+	}
+
+	now := time.Now()
+	details := models.NewBucketDetails(bucket, fruitsInBucket)
+	s.Markdown.PriceBucket(&details, now)
+	details.CalcExpiring(now)
+	respondWithJSON(w, http.StatusOK, details)
+}
+
+// UpdateBucket altera a capacidade ou o aviso de expiração de um balde. Não
+// é possível reduzir a capacidade abaixo da quantidade de frutas que ele já
+// contém; "expiry_warning_seconds" igual a zero desliga o aviso.
+func (s *Server) UpdateBucket(w http.ResponseWriter, r *http.Request) {
+	bucketID, err := strconv.Atoi(chi.URLParam(r, "bucketID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de balde inválido")
+		return
 	}
+
+	var payload models.UpdateBucketRequest
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		respondWithError(w, http.StatusBadRequest, "Payload inválido")
 		return
 	}
 
-	// Verifica a capacidade do balde
-	bucket := models.Bucket{}
-	if err := bucket.GetByID(bucketID); err != nil {
-		if err == sql.ErrNoRows {
+	if payload.Capacity == nil && payload.ExpiryWarningSeconds == nil {
+		respondWithError(w, http.StatusBadRequest, "Informe ao menos um dos campos 'capacity' ou 'expiry_warning_seconds'")
+		return
+	}
+
+	if payload.Capacity != nil && *payload.Capacity <= 0 {
+		respondWithError(w, http.StatusBadRequest, "A capacidade deve ser maior que zero")
+		return
+	}
+
+	if payload.ExpiryWarningSeconds != nil && *payload.ExpiryWarningSeconds < 0 {
+		respondWithError(w, http.StatusBadRequest, "Campo 'expiry_warning_seconds' não pode ser negativo")
+		return
+	}
+
+	bucket, err := s.Buckets.UpdateBucket(bucketID, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrBucketNotFound):
 			respondWithError(w, http.StatusNotFound, "Balde não encontrado")
-			return
+		case errors.Is(err, models.ErrCapacityBelowCount):
+			respondWithError(w, http.StatusConflict, "A capacidade não pode ser menor que a quantidade de frutas no balde")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Erro ao atualizar o balde")
 		}
+		return
+	}
 
-		respondWithError(w, http.StatusInternalServerError, "Erro ao verificar capacidade do balde")
+	respondWithJSON(w, http.StatusOK, bucket)
+}
+
+// maxBatchSize limita a quantidade de itens das operações em lote.
+const maxBatchSize = 1000
+
+// DepositFruit deposita uma fruta em um balde. Com "fruit_ids", deposita um
+// lote no modo informado em "mode" (all_or_nothing, o padrão, ou best_effort)
+// e responde com o resultado de cada fruta.
+func (s *Server) DepositFruit(w http.ResponseWriter, r *http.Request) {
+	bucketID, err := strconv.Atoi(chi.URLParam(r, "bucketID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de balde inválido")
 		return
 	}
 
-	fruitsInBucket, err := models.Fruit{}.GetFruitsInBucket(bucket.ID)
-	if err != nil && err != sql.ErrNoRows {
-		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar frutas do balde")
+	var payload struct {
+		FruitID  int    `json:"fruit_id"`
+		FruitIDs []int  `json:"fruit_ids"`
+		Mode     string `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Payload inválido")
 		return
 	}
 
-	if len(fruitsInBucket) >= bucket.Capacity {
-		respondWithError(w, http.StatusBadRequest, "Capacidade máxima do balde atingida")
+	if payload.FruitIDs != nil {
+		s.depositFruits(w, bucketID, payload.FruitIDs, payload.Mode)
 		return
 	}
 
-	// Verifica se a fruta existe e não está em outro balde
-	fruit := models.Fruit{}
-	if err := fruit.GetByID(payload.FruitID); err != nil {
-		if err == sql.ErrNoRows {
-			respondWithError(w, http.StatusNotFound, "Fruta não encontrada")
-			return
-		}
+	// Deposita a fruta; o store verifica a existência do balde e da fruta,
+	// a capacidade, o peso e o volume e se a fruta já está em outro balde.
+	if err := s.Fruits.AddFruitToBucket(payload.FruitID, bucketID); err != nil {
+		status, message := depositError(err)
+		respondWithError(w, status, message)
+		return
+	}
 
-		respondWithError(w, http.StatusInternalServerError, "Erro ao verificar a fruta")
+	s.publishDeposited(bucketID, payload.FruitID)
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Fruta depositada com sucesso"})
+}
+
+// depositFruits trata o depósito em lote de DepositFruit.
+func (s *Server) depositFruits(w http.ResponseWriter, bucketID int, fruitIDs []int, mode string) {
+	switch {
+	case len(fruitIDs) == 0:
+		respondWithError(w, http.StatusBadRequest, "Informe ao menos uma fruta")
+		return
+	case len(fruitIDs) > maxBatchSize:
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("O lote aceita no máximo %d frutas", maxBatchSize))
 		return
 	}
 
-	if fruit.BucketID.Valid {
-		respondWithError(w, http.StatusBadRequest, "A fruta já está em outro balde")
+	if mode == "" {
+		mode = models.DepositModeAllOrNothing
+	}
+	if mode != models.DepositModeAllOrNothing && mode != models.DepositModeBestEffort {
+		respondWithError(w, http.StatusBadRequest, "Modo de depósito inválido")
 		return
 	}
 
-	// Deposita a fruta
-	_, err = fruit.AddToBucket(bucket.ID)
+	errs, err := s.Fruits.AddFruitsToBucket(bucketID, fruitIDs, mode == models.DepositModeAllOrNothing)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Erro ao depositar a fruta")
+		status, message := depositError(err)
+		respondWithError(w, status, message)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Fruta depositada com sucesso"})
+	var deposited []int
+	results := make([]models.DepositResult, len(fruitIDs))
+	for i, err := range errs {
+		results[i] = models.DepositResult{FruitID: fruitIDs[i], Deposited: err == nil}
+		if err != nil {
+			_, results[i].Error = depositError(err)
+			continue
+		}
+		deposited = append(deposited, fruitIDs[i])
+	}
+
+	if len(deposited) > 0 {
+		s.publishDeposited(bucketID, deposited...)
+	}
+
+	// No modo "tudo ou nada", um lote recusado não alterou nada.
+	status := http.StatusOK
+	if mode == models.DepositModeAllOrNothing && len(deposited) < len(fruitIDs) {
+		status = http.StatusConflict
+	}
+
+	respondWithJSON(w, status, struct {
+		Deposited int                    `json:"deposited"`
+		Results   []models.DepositResult `json:"results"`
+	}{len(deposited), results})
+}
+
+// depositError traduz um erro de depósito em status HTTP e mensagem.
+func depositError(err error) (int, string) {
+	switch {
+	case errors.Is(err, models.ErrBucketNotFound):
+		return http.StatusNotFound, "Balde não encontrado"
+	case errors.Is(err, models.ErrFruitNotFound):
+		return http.StatusNotFound, "Fruta não encontrada"
+	case errors.Is(err, models.ErrWeightLimitExceeded):
+		return http.StatusBadRequest, "Peso máximo do balde excedido"
+	case errors.Is(err, models.ErrVolumeLimitExceeded):
+		return http.StatusBadRequest, "Volume máximo do balde excedido"
+	case errors.Is(err, models.ErrBucketFull):
+		return http.StatusBadRequest, "Capacidade máxima do balde atingida"
+	case errors.Is(err, models.ErrFruitInBucket):
+		return http.StatusBadRequest, "A fruta já está em outro balde"
+	case errors.Is(err, models.ErrBatchAborted):
+		return http.StatusConflict, "Lote cancelado por falha em outra fruta"
+	default:
+		return http.StatusInternalServerError, "Erro ao depositar a fruta"
+	}
+}
+
+// MoveFruit transfere uma fruta de um balde para outro em uma única operação.
+func (s *Server) MoveFruit(w http.ResponseWriter, r *http.Request) {
+	bucketID, err := strconv.Atoi(chi.URLParam(r, "bucketID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de balde inválido")
+		return
+	}
+	fruitID, err := strconv.Atoi(chi.URLParam(r, "fruitID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de fruta inválido")
+		return
+	}
+
+	var payload struct {
+		TargetBucketID int `json:"target_bucket_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Payload inválido")
+		return
+	}
+
+	if payload.TargetBucketID == bucketID {
+		respondWithError(w, http.StatusBadRequest, "O balde de destino deve ser diferente do balde de origem")
+		return
+	}
+
+	if err := s.Fruits.MoveFruit(fruitID, bucketID, payload.TargetBucketID); err != nil {
+		switch {
+		case errors.Is(err, models.ErrBucketNotFound):
+			respondWithError(w, http.StatusNotFound, "Balde de destino não encontrado")
+		case errors.Is(err, models.ErrFruitNotFound), errors.Is(err, models.ErrFruitNotInBucket):
+			respondWithError(w, http.StatusNotFound, "Fruta não encontrada neste balde")
+		case errors.Is(err, models.ErrBucketFull):
+			_, message := depositError(err)
+			respondWithError(w, http.StatusBadRequest, message)
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Erro ao mover a fruta")
+		}
+		return
+	}
+
+	s.publishMoved([]models.FruitMove{{FruitID: fruitID, FromBucketID: bucketID, ToBucketID: payload.TargetBucketID}})
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Fruta movida com sucesso"})
 }
 
 // RemoveFruitFromBucket remove uma fruta de um balde.
-func RemoveFruitFromBucket(w http.ResponseWriter, r *http.Request) {
+func (s *Server) RemoveFruitFromBucket(w http.ResponseWriter, r *http.Request) {
 	bucketID, err := strconv.Atoi(chi.URLParam(r, "bucketID"))
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "ID de balde inválido")
@@ -180,7 +487,7 @@ func RemoveFruitFromBucket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rowsAffected, err := models.Fruit{}.RemoveFromBucket(fruitID, bucketID)
+	rowsAffected, err := s.Fruits.RemoveFruitFromBucket(fruitID, bucketID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Erro ao remover a fruta do balde")
 		return
@@ -191,5 +498,65 @@ func RemoveFruitFromBucket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.publishRemoved(bucketID, fruitID)
+
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Fruta removida com sucesso"})
 }
+
+// RebalanceBuckets calcula um plano de transferências para o objetivo
+// informado ("min_variance", o padrão, ou "consolidate") e o aplica em uma
+// única transação. Com dry_run=true, apenas devolve o plano.
+func (s *Server) RebalanceBuckets(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Parâmetro 'dry_run' deve ser true ou false")
+			return
+		}
+	}
+
+	var payload struct {
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Payload inválido")
+		return
+	}
+	if payload.Target == "" {
+		payload.Target = rebalance.TargetMinVariance
+	}
+
+	details, err := s.Buckets.ListBucketDetails(models.BucketFilter{SortBy: models.BucketSortID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar baldes")
+		return
+	}
+
+	plan, err := rebalance.NewPlan(details, payload.Target)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Parâmetro 'target' deve ser min_variance ou consolidate")
+		return
+	}
+
+	if !dryRun {
+		// O store refaz as verificações de cada movimento; se os baldes
+		// mudaram desde a leitura acima, nada é aplicado.
+		if err := s.Fruits.ApplyMoves(plan.Moves); err != nil {
+			if errors.Is(err, models.ErrNotFound) || errors.Is(err, models.ErrBucketFull) || errors.Is(err, models.ErrFruitNotInBucket) {
+				respondWithError(w, http.StatusConflict, "Os baldes foram alterados durante o rebalanceamento; tente novamente")
+				return
+			}
+
+			respondWithError(w, http.StatusInternalServerError, "Erro ao aplicar o rebalanceamento")
+			return
+		}
+
+		s.publishMoved(plan.Moves)
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		rebalance.Plan
+		DryRun bool `json:"dry_run"`
+	}{plan, dryRun})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// EventPublisher recebe os eventos das operações da API, como o dispatcher
+// de webhooks. Publish é chamado depois que a operação foi gravada e não
+// deve bloquear a resposta.
+type EventPublisher interface {
+	Publish(event string, data any)
+}
+
+// noopPublisher descarta os eventos.
+type noopPublisher struct{}
+
+func (noopPublisher) Publish(string, any) {}
+
+// publishCreated publica fruit.created para cada fruta e, para as que já
+// nasceram dentro de um balde, fruit.deposited e bucket.full.
+func (s *Server) publishCreated(fruits []models.Fruit) {
+	var buckets []int
+	seen := make(map[int]bool)
+	for _, fruit := range fruits {
+		s.Events.Publish(models.EventFruitCreated, fruit)
+
+		if !fruit.BucketID.Valid {
+			continue
+		}
+
+		bucketID := int(fruit.BucketID.Int64)
+		s.Events.Publish(models.EventFruitDeposited, models.FruitBucketEvent{BucketID: bucketID, Fruit: fruit})
+		if !seen[bucketID] {
+			seen[bucketID] = true
+			buckets = append(buckets, bucketID)
+		}
+	}
+
+	for _, bucketID := range buckets {
+		s.publishIfFull(bucketID)
+	}
+}
+
+// publishDeposited publica fruit.deposited para as frutas depositadas no
+// balde e bucket.full se elas o encheram.
+func (s *Server) publishDeposited(bucketID int, fruitIDs ...int) {
+	for _, fruitID := range fruitIDs {
+		fruit, err := s.Fruits.GetFruit(fruitID)
+		if err != nil {
+			log.Println("Erro ao buscar a fruta do evento:", err)
+			continue
+		}
+
+		s.Events.Publish(models.EventFruitDeposited, models.FruitBucketEvent{BucketID: bucketID, Fruit: fruit})
+	}
+
+	s.publishIfFull(bucketID)
+}
+
+// publishRemoved publica fruit.removed para a fruta que saiu do balde.
+func (s *Server) publishRemoved(bucketID, fruitID int) {
+	fruit, err := s.Fruits.GetFruit(fruitID)
+	if err != nil {
+		log.Println("Erro ao buscar a fruta do evento:", err)
+		return
+	}
+
+	s.Events.Publish(models.EventFruitRemoved, models.FruitBucketEvent{BucketID: bucketID, Fruit: fruit})
+}
+
+// publishMoved publica a saída e a entrada de cada fruta transferida.
+func (s *Server) publishMoved(moves []models.FruitMove) {
+	for _, move := range moves {
+		s.publishRemoved(move.FromBucketID, move.FruitID)
+		s.publishDeposited(move.ToBucketID, move.FruitID)
+	}
+}
+
+// publishIfFull publica bucket.full se o balde está na capacidade em
+// quantidade de frutas, o mesmo critério do filtro "full" da listagem. É
+// chamado depois de cada depósito, inclusive de cada movimento de uma
+// transferência ou rebalanceamento, então o evento se repete sempre que um
+// depósito deixa o balde cheio, e não só na primeira vez que ele enche.
+func (s *Server) publishIfFull(bucketID int) {
+	bucket, err := s.Buckets.GetBucket(bucketID)
+	if err != nil {
+		log.Println("Erro ao buscar o balde do evento:", err)
+		return
+	}
+
+	fruits, err := s.Fruits.GetFruitsInBucket(bucketID)
+	if err != nil {
+		log.Println("Erro ao buscar as frutas do balde do evento:", err)
+		return
+	}
+
+	if len(fruits) >= bucket.Capacity {
+		s.Events.Publish(models.EventBucketFull, models.NewBucketDetails(bucket, fruits))
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// Limites da projeção de estoque.
+const (
+	defaultForecastHorizon = 24 * time.Hour
+	maxForecastHorizon     = 30 * 24 * time.Hour
+	maxForecastPoints      = 1000
+)
+
+// ForecastBucket projeta o valor total e a quantidade de frutas de um balde
+// conforme elas expiram, até o horizonte informado em "horizon".
+func (s *Server) ForecastBucket(w http.ResponseWriter, r *http.Request) {
+	bucketID, err := strconv.Atoi(chi.URLParam(r, "bucketID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de balde inválido")
+		return
+	}
+
+	horizon, step, err := parseForecastParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bucket, err := s.Buckets.GetBucket(bucketID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Balde não encontrado")
+			return
+		}
+
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar o balde")
+		return
+	}
+
+	fruitsInBucket, err := s.Fruits.GetFruitsInBucket(bucket.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar frutas do balde")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		BucketID int `json:"bucket_id"`
+		models.Forecast
+	}{bucket.ID, models.NewForecast(fruitsInBucket, time.Now(), horizon, step)})
+}
+
+// ForecastBuckets projeta o estoque somado de todos os baldes, mostrando
+// quanto valor será perdido por expiração até o horizonte. Frutas soltas
+// não entram na projeção.
+func (s *Server) ForecastBuckets(w http.ResponseWriter, r *http.Request) {
+	horizon, step, err := parseForecastParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	details, err := s.Buckets.ListBucketDetails(models.BucketFilter{SortBy: models.BucketSortID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar baldes")
+		return
+	}
+
+	var fruits []models.Fruit
+	for _, d := range details {
+		fruits = append(fruits, d.Fruits...)
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		BucketCount int `json:"bucket_count"`
+		models.Forecast
+	}{len(details), models.NewForecast(fruits, time.Now(), horizon, step)})
+}
+
+// parseForecastParams lê o horizonte (padrão de 24h, de 1s a 720h) e o
+// intervalo opcional entre os pontos da projeção, de ao menos 1s e sem passar
+// de maxForecastPoints pontos no horizonte.
+func parseForecastParams(r *http.Request) (time.Duration, time.Duration, error) {
+	query := r.URL.Query()
+
+	horizon := defaultForecastHorizon
+	if v := query.Get("horizon"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second || d > maxForecastHorizon {
+			return 0, 0, errors.New("Parâmetro 'horizon' deve ser uma duração entre 1s e 720h, como 48h")
+		}
+		horizon = d
+	}
+
+	var step time.Duration
+	if v := query.Get("step"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			return 0, 0, errors.New("Parâmetro 'step' deve ser uma duração de ao menos 1s, como 1h")
+		}
+		if horizon/d > maxForecastPoints {
+			return 0, 0, fmt.Errorf("A projeção aceita no máximo %d pontos; aumente o 'step'", maxForecastPoints)
+		}
+		step = d
+	}
+
+	return horizon, step, nil
+}
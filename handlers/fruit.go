@@ -2,65 +2,536 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/mr-utzig/planne-test/models"
+	"github.com/mr-utzig/planne-test/placement"
 )
 
-// CreateFruit cria uma nova fruta.
-func CreateFruit(w http.ResponseWriter, r *http.Request) {
+// CreateFruit cria uma nova fruta. Com "type_id", os campos não informados
+// vêm dos valores padrão do tipo.
+func (s *Server) CreateFruit(w http.ResponseWriter, r *http.Request) {
 	var payload models.CreateFruitRequest
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Payload inválido")
+		respondWithError(w, http.StatusBadRequest, payloadError(err))
 		return
 	}
 
-	if payload.Name == "" || payload.Price <= 0 || payload.ExpiresInSeconds <= 0 {
-		respondWithError(w, http.StatusBadRequest, "Campos 'name', 'price' e 'expires_in_seconds' são obrigatórios e devem ser positivos")
+	if err := s.applyFruitType(&payload); err != nil {
+		status, message := fruitTypeError(err)
+		respondWithError(w, status, message)
 		return
 	}
 
-	fruit, err := payload.InsertFruitFromPayload()
-	if err != nil {
+	if message := validateCreateFruit(payload); message != "" {
+		respondWithError(w, http.StatusBadRequest, message)
+		return
+	}
+
+	fruit := payload.ToFruit()
+	if err := s.Fruits.CreateFruit(&fruit); err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Erro ao criar a fruta")
 		return
 	}
 
+	s.Expirations.Schedule(fruit.ID, fruit.ExpirationTime)
+	s.publishCreated([]models.Fruit{fruit})
+
+	fruit.EffectivePrice = s.Markdown.Price(fruit, time.Now())
 	respondWithJSON(w, http.StatusCreated, fruit)
 }
 
+// validateCreateFruit devolve a mensagem de erro do payload, ou "" se ele for
+// válido.
+func validateCreateFruit(payload models.CreateFruitRequest) string {
+	if payload.Name == "" || payload.Price == nil || !payload.Price.IsPositive() || payload.ExpiresInSeconds <= 0 {
+		return "Campos 'name', 'price' e 'expires_in_seconds' são obrigatórios e devem ser positivos"
+	}
+
+	if payload.ExpiresInSeconds > models.MaxLifetimeSeconds {
+		return fmt.Sprintf("Campo 'expires_in_seconds' aceita no máximo %d segundos", models.MaxLifetimeSeconds)
+	}
+
+	if !positiveOrNil(payload.Weight) || !positiveOrNil(payload.Volume) {
+		return "Campos 'weight' e 'volume' devem ser positivos quando informados"
+	}
+
+	return ""
+}
+
+// CreateFruits cria um lote de frutas em uma única transação. Cada item pode
+// trazer um "type_id", como em CreateFruit, e um "bucket_id" para já nascer
+// dentro do balde; se algum item for inválido, nenhuma fruta é criada e os
+// erros são devolvidos pela posição.
+func (s *Server) CreateFruits(w http.ResponseWriter, r *http.Request) {
+	var payload []models.BatchFruitRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondWithError(w, http.StatusBadRequest, payloadError(err))
+		return
+	}
+
+	switch {
+	case len(payload) == 0:
+		respondWithError(w, http.StatusBadRequest, "Informe ao menos uma fruta")
+		return
+	case len(payload) > maxBatchSize:
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("O lote aceita no máximo %d frutas", maxBatchSize))
+		return
+	}
+
+	var itemErrors []models.BatchItemError
+	fruits := make([]models.Fruit, len(payload))
+	for i, item := range payload {
+		if err := s.applyFruitType(&item.CreateFruitRequest); err != nil {
+			status, message := fruitTypeError(err)
+			if status == http.StatusInternalServerError {
+				respondWithError(w, status, message)
+				return
+			}
+			itemErrors = append(itemErrors, models.BatchItemError{Index: i, Error: message})
+			continue
+		}
+		if message := validateCreateFruit(item.CreateFruitRequest); message != "" {
+			itemErrors = append(itemErrors, models.BatchItemError{Index: i, Error: message})
+			continue
+		}
+		fruits[i] = item.ToFruit()
+	}
+
+	if itemErrors == nil {
+		errs, err := s.Fruits.CreateFruits(fruits)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erro ao criar as frutas")
+			return
+		}
+
+		for i, err := range errs {
+			if err != nil {
+				_, message := depositError(err)
+				itemErrors = append(itemErrors, models.BatchItemError{Index: i, Error: message})
+			}
+		}
+	}
+
+	if itemErrors != nil {
+		respondWithJSON(w, http.StatusBadRequest, struct {
+			Error  string                  `json:"error"`
+			Errors []models.BatchItemError `json:"errors"`
+		}{"Nenhuma fruta foi criada", itemErrors})
+		return
+	}
+
+	for _, fruit := range fruits {
+		s.Expirations.Schedule(fruit.ID, fruit.ExpirationTime)
+	}
+	s.publishCreated(fruits)
+
+	s.Markdown.PriceFruits(fruits, time.Now())
+	respondWithJSON(w, http.StatusCreated, fruits)
+}
+
+// Limites de paginação das listagens.
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// ListFruits lista as frutas, soltas ou em baldes, com filtros, ordenação e
+// paginação informados na query string. Com "group_by=type", as frutas da
+// página são agrupadas pelo tipo do catálogo.
+func (s *Server) ListFruits(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFruitFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy != "" && groupBy != "type" {
+		respondWithError(w, http.StatusBadRequest, "Parâmetro 'group_by' deve ser type")
+		return
+	}
+
+	fruits, err := s.Fruits.ListFruits(filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar frutas")
+		return
+	}
+
+	s.Markdown.PriceFruits(fruits, time.Now())
+
+	if groupBy == "type" {
+		types, err := s.FruitTypes.ListFruitTypes()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erro ao buscar tipos de fruta")
+			return
+		}
+
+		respondWithJSON(w, http.StatusOK, models.GroupFruitsByType(fruits, types))
+		return
+	}
+
+	if fruits == nil {
+		fruits = []models.Fruit{}
+	}
+
+	respondWithJSON(w, http.StatusOK, fruits)
+}
+
+// ListExpiringFruits lista as frutas que expiram dentro do prazo informado em
+// "within" (padrão de 1h), incluindo as já vencidas que ainda aguardam a
+// remoção. Aceita os mesmos filtros da listagem de frutas e, por padrão,
+// ordena pela expiração.
+func (s *Server) ListExpiringFruits(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFruitFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	within := time.Hour
+	if v := r.URL.Query().Get("within"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 || d > maxForecastHorizon {
+			respondWithError(w, http.StatusBadRequest, "Parâmetro 'within' deve ser uma duração entre 0s e 720h, como 1h")
+			return
+		}
+		within = d
+	}
+
+	if r.URL.Query().Get("sort") == "" {
+		filter.SortBy = models.FruitSortExpiration
+	}
+
+	// ExpiresBefore é exclusivo; o prazo inclui o último segundo.
+	now := time.Now()
+	cutoff := now.Add(within).Unix() + 1
+	if filter.ExpiresBefore == 0 || cutoff < filter.ExpiresBefore {
+		filter.ExpiresBefore = cutoff
+	}
+
+	fruits, err := s.Fruits.ListFruits(filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar frutas")
+		return
+	}
+
+	if fruits == nil {
+		fruits = []models.Fruit{}
+	}
+
+	s.Markdown.PriceFruits(fruits, now)
+	respondWithJSON(w, http.StatusOK, fruits)
+}
+
+// parseFruitFilter lê os parâmetros da listagem de frutas. Os erros
+// devolvidos já estão prontos para serem enviados ao cliente.
+func parseFruitFilter(r *http.Request) (models.FruitFilter, error) {
+	query := r.URL.Query()
+	filter := models.FruitFilter{
+		Name:   query.Get("name"),
+		SortBy: models.FruitSortID,
+		Limit:  defaultPageSize,
+	}
+
+	if v := query.Get("in_bucket"); v != "" {
+		inBucket, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("Parâmetro 'in_bucket' deve ser true ou false")
+		}
+		filter.InBucket = &inBucket
+	}
+
+	if v := query.Get("bucket_id"); v != "" {
+		bucketID, err := strconv.Atoi(v)
+		if err != nil || bucketID <= 0 {
+			return filter, errors.New("Parâmetro 'bucket_id' inválido")
+		}
+		filter.BucketID = bucketID
+	}
+
+	if v := query.Get("type_id"); v != "" {
+		typeID, err := strconv.Atoi(v)
+		if err != nil || typeID <= 0 {
+			return filter, errors.New("Parâmetro 'type_id' inválido")
+		}
+		filter.TypeID = typeID
+	}
+
+	if v := query.Get("currency"); v != "" {
+		if !models.ValidCurrency(v) {
+			return filter, errors.New("Parâmetro 'currency' deve ser uma moeda aceita: " + strings.Join(models.Currencies(), ", "))
+		}
+		filter.Currency = v
+	}
+
+	// As faixas de preço são quantias na moeda do filtro e, por isso, também
+	// restringem a listagem a essa moeda.
+	for name, target := range map[string]*int64{"min_price": &filter.MinPrice, "max_price": &filter.MaxPrice} {
+		if v := query.Get(name); v != "" {
+			currency := filter.Currency
+			if currency == "" {
+				currency = models.DefaultCurrency
+			}
+
+			price, err := models.ParseMoney(v, currency)
+			if err != nil || price.Amount < 0 {
+				return filter, fmt.Errorf("Parâmetro '%s' inválido", name)
+			}
+			*target = price.Amount
+			filter.Currency = currency
+		}
+	}
+
+	if filter.MinPrice != 0 && filter.MaxPrice != 0 && filter.MinPrice > filter.MaxPrice {
+		return filter, errors.New("O parâmetro 'min_price' deve ser menor ou igual a 'max_price'")
+	}
+
+	var err error
+	if filter.ExpiresBefore, err = queryInt64(r, "expires_before"); err != nil || filter.ExpiresBefore < 0 {
+		return filter, errors.New("Parâmetro 'expires_before' inválido")
+	}
+	if filter.ExpiresAfter, err = queryInt64(r, "expires_after"); err != nil || filter.ExpiresAfter < 0 {
+		return filter, errors.New("Parâmetro 'expires_after' inválido")
+	}
+
+	switch v := query.Get("sort"); v {
+	case "":
+	case models.FruitSortID, models.FruitSortPrice, models.FruitSortName, models.FruitSortExpiration:
+		filter.SortBy = v
+	default:
+		return filter, errors.New("Parâmetro 'sort' deve ser id, price, name ou expiration")
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, errors.New("Parâmetro 'order' deve ser asc ou desc")
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxPageSize {
+			return filter, fmt.Errorf("Parâmetro 'limit' deve estar entre 1 e %d", maxPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return filter, errors.New("Parâmetro 'offset' inválido")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, nil
+}
+
+// GetFruit retorna uma fruta com o tempo restante até a expiração e o balde
+// em que ela está.
+func (s *Server) GetFruit(w http.ResponseWriter, r *http.Request) {
+	fruitID, err := strconv.Atoi(chi.URLParam(r, "fruitID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de fruta inválido")
+		return
+	}
+
+	fruit, err := s.Fruits.GetFruit(fruitID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Fruta não encontrada")
+			return
+		}
+
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar a fruta")
+		return
+	}
+
+	var bucket *models.Bucket
+	if fruit.BucketID.Valid {
+		b, err := s.Buckets.GetBucket(int(fruit.BucketID.Int64))
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erro ao buscar o balde da fruta")
+			return
+		}
+
+		bucket = &b
+	}
+
+	now := time.Now()
+	fruit.EffectivePrice = s.Markdown.Price(fruit, now)
+	respondWithJSON(w, http.StatusOK, models.NewFruitDetails(fruit, bucket, now))
+}
+
+// PlaceFruit deposita uma fruta solta no balde escolhido automaticamente.
+// O corpo é opcional; "strategy" troca a estratégia padrão do servidor.
+func (s *Server) PlaceFruit(w http.ResponseWriter, r *http.Request) {
+	fruitID, err := strconv.Atoi(chi.URLParam(r, "fruitID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de fruta inválido")
+		return
+	}
+
+	var payload struct {
+		Strategy string `json:"strategy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Payload inválido")
+		return
+	}
+
+	strategy := s.Placement
+	if payload.Strategy != "" {
+		if strategy, err = placement.Lookup(payload.Strategy); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Estratégia de alocação inválida")
+			return
+		}
+	}
+
+	bucketID, err := placement.Place(s.Buckets, s.Fruits, fruitID, strategy)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrFruitNotFound):
+			respondWithError(w, http.StatusNotFound, "Fruta não encontrada")
+		case errors.Is(err, models.ErrFruitInBucket):
+			respondWithError(w, http.StatusBadRequest, "A fruta já está em outro balde")
+		case errors.Is(err, placement.ErrNoBucketAvailable):
+			respondWithError(w, http.StatusConflict, "Nenhum balde com espaço disponível")
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Erro ao alocar a fruta")
+		}
+		return
+	}
+
+	s.publishDeposited(bucketID, fruitID)
+
+	fruit, err := s.Fruits.GetFruit(fruitID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar a fruta")
+		return
+	}
+
+	bucket, err := s.Buckets.GetBucket(bucketID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar o balde da fruta")
+		return
+	}
+
+	now := time.Now()
+	fruit.EffectivePrice = s.Markdown.Price(fruit, now)
+	respondWithJSON(w, http.StatusOK, models.NewFruitDetails(fruit, &bucket, now))
+}
+
+// UpdateFruit altera o nome, o preço ou estende a expiração de uma fruta.
+func (s *Server) UpdateFruit(w http.ResponseWriter, r *http.Request) {
+	fruitID, err := strconv.Atoi(chi.URLParam(r, "fruitID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de fruta inválido")
+		return
+	}
+
+	var payload models.UpdateFruitRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondWithError(w, http.StatusBadRequest, payloadError(err))
+		return
+	}
+
+	if payload.Name == nil && payload.Price == nil && payload.ExtendExpirationSeconds == 0 {
+		respondWithError(w, http.StatusBadRequest, "Informe ao menos um dos campos 'name', 'price' ou 'extend_expiration_seconds'")
+		return
+	}
+
+	if (payload.Name != nil && *payload.Name == "") || (payload.Price != nil && !payload.Price.IsPositive()) || payload.ExtendExpirationSeconds < 0 {
+		respondWithError(w, http.StatusBadRequest, "Campos 'name', 'price' e 'extend_expiration_seconds' devem ser positivos quando informados")
+		return
+	}
+
+	if payload.ExtendExpirationSeconds > models.MaxLifetimeSeconds {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Campo 'extend_expiration_seconds' aceita no máximo %d segundos", models.MaxLifetimeSeconds))
+		return
+	}
+
+	fruit, err := s.Fruits.UpdateFruit(fruitID, payload)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Fruta não encontrada")
+			return
+		}
+		if errors.Is(err, models.ErrExpirationTooFar) {
+			respondWithError(w, http.StatusBadRequest, "A expiração estendida ultrapassaria o limite permitido")
+			return
+		}
+
+		respondWithError(w, http.StatusInternalServerError, "Erro ao atualizar a fruta")
+		return
+	}
+
+	if payload.ExtendExpirationSeconds > 0 {
+		s.Expirations.Schedule(fruit.ID, fruit.ExpirationTime)
+	}
+
+	fruit.EffectivePrice = s.Markdown.Price(fruit, time.Now())
+	respondWithJSON(w, http.StatusOK, fruit)
+}
+
 // DeleteFruit exclui uma fruta permanentemente.
-func DeleteFruit(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DeleteFruit(w http.ResponseWriter, r *http.Request) {
 	fruitID, err := strconv.Atoi(chi.URLParam(r, "fruitID"))
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, "ID de fruta inválido")
 		return
 	}
 
-	err = models.Fruit{}.DeleteByID(fruitID)
+	err = s.Fruits.DeleteFruit(fruitID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Erro ao excluir a fruta")
 		return
 	}
 
+	s.Expirations.Cancel(fruitID)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// StartExpirationJanitor inicia um processo em background que verifica e remove
-// frutas expiradas em intervalos regulares.
-func StartExpirationJanitor(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// ListExpiredFruits lista as frutas arquivadas após expirar. Os parâmetros
+// opcionais `from` e `to` (timestamps Unix) filtram pela data de expiração.
+func (s *Server) ListExpiredFruits(w http.ResponseWriter, r *http.Request) {
+	from, err := queryInt64(r, "from")
+	if err != nil || from < 0 {
+		respondWithError(w, http.StatusBadRequest, "Parâmetro 'from' inválido")
+		return
+	}
 
-	for range ticker.C {
-		rowsAffected := models.Fruit{}.DeleteExpireds()
+	to, err := queryInt64(r, "to")
+	if err != nil || to < 0 {
+		respondWithError(w, http.StatusBadRequest, "Parâmetro 'to' inválido")
+		return
+	}
 
-		if rowsAffected > 0 {
-			log.Println(rowsAffected, "Fruta(s) expirada(s) removida(s).")
-		}
+	if from != 0 && to != 0 && from > to {
+		respondWithError(w, http.StatusBadRequest, "O parâmetro 'from' deve ser anterior a 'to'")
+		return
+	}
+
+	fruits, err := s.Fruits.ListExpiredFruits(from, to)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar frutas expiradas")
+		return
 	}
+
+	if fruits == nil {
+		fruits = []models.ExpiredFruit{}
+	}
+
+	respondWithJSON(w, http.StatusOK, fruits)
 }
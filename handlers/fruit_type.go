@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// shelfLifeTooLong é a mensagem para validades acima de
+// models.MaxLifetimeSeconds.
+var shelfLifeTooLong = fmt.Sprintf("Campo 'shelf_life_seconds' aceita no máximo %d segundos", models.MaxLifetimeSeconds)
+
+// CreateFruitType cadastra um tipo de fruta no catálogo.
+func (s *Server) CreateFruitType(w http.ResponseWriter, r *http.Request) {
+	var fruitType models.FruitType
+	if err := json.NewDecoder(r.Body).Decode(&fruitType); err != nil {
+		respondWithError(w, http.StatusBadRequest, payloadError(err))
+		return
+	}
+
+	if fruitType.Name == "" || fruitType.ShelfLifeSeconds <= 0 || !fruitType.DefaultPrice.IsPositive() {
+		respondWithError(w, http.StatusBadRequest, "Campos 'name', 'shelf_life_seconds' e 'default_price' são obrigatórios e devem ser positivos")
+		return
+	}
+
+	if fruitType.ShelfLifeSeconds > models.MaxLifetimeSeconds {
+		respondWithError(w, http.StatusBadRequest, shelfLifeTooLong)
+		return
+	}
+
+	if err := s.FruitTypes.CreateFruitType(&fruitType); err != nil {
+		status, message := fruitTypeError(err)
+		respondWithError(w, status, message)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, fruitType)
+}
+
+// ListFruitTypes lista o catálogo de tipos de fruta.
+func (s *Server) ListFruitTypes(w http.ResponseWriter, r *http.Request) {
+	types, err := s.FruitTypes.ListFruitTypes()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar tipos de fruta")
+		return
+	}
+
+	if types == nil {
+		types = []models.FruitType{}
+	}
+
+	respondWithJSON(w, http.StatusOK, types)
+}
+
+// GetFruitType retorna um tipo de fruta.
+func (s *Server) GetFruitType(w http.ResponseWriter, r *http.Request) {
+	typeID, err := strconv.Atoi(chi.URLParam(r, "typeID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de tipo de fruta inválido")
+		return
+	}
+
+	fruitType, err := s.FruitTypes.GetFruitType(typeID)
+	if err != nil {
+		status, message := fruitTypeError(err)
+		respondWithError(w, status, message)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, fruitType)
+}
+
+// UpdateFruitType altera o nome ou os valores padrão de um tipo de fruta. As
+// frutas já criadas não são alteradas.
+func (s *Server) UpdateFruitType(w http.ResponseWriter, r *http.Request) {
+	typeID, err := strconv.Atoi(chi.URLParam(r, "typeID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de tipo de fruta inválido")
+		return
+	}
+
+	var payload models.UpdateFruitTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondWithError(w, http.StatusBadRequest, payloadError(err))
+		return
+	}
+
+	if payload.Name == nil && payload.ShelfLifeSeconds == nil && payload.DefaultPrice == nil {
+		respondWithError(w, http.StatusBadRequest, "Informe ao menos um dos campos 'name', 'shelf_life_seconds' ou 'default_price'")
+		return
+	}
+
+	if (payload.Name != nil && *payload.Name == "") || (payload.ShelfLifeSeconds != nil && *payload.ShelfLifeSeconds <= 0) || (payload.DefaultPrice != nil && !payload.DefaultPrice.IsPositive()) {
+		respondWithError(w, http.StatusBadRequest, "Campos 'name', 'shelf_life_seconds' e 'default_price' devem ser positivos quando informados")
+		return
+	}
+
+	if payload.ShelfLifeSeconds != nil && *payload.ShelfLifeSeconds > models.MaxLifetimeSeconds {
+		respondWithError(w, http.StatusBadRequest, shelfLifeTooLong)
+		return
+	}
+
+	fruitType, err := s.FruitTypes.UpdateFruitType(typeID, payload)
+	if err != nil {
+		status, message := fruitTypeError(err)
+		respondWithError(w, status, message)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, fruitType)
+}
+
+// DeleteFruitType exclui um tipo de fruta, se nenhuma fruta ativa for dele.
+func (s *Server) DeleteFruitType(w http.ResponseWriter, r *http.Request) {
+	typeID, err := strconv.Atoi(chi.URLParam(r, "typeID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de tipo de fruta inválido")
+		return
+	}
+
+	if err := s.FruitTypes.DeleteFruitType(typeID); err != nil {
+		status, message := fruitTypeError(err)
+		respondWithError(w, status, message)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fruitTypeError traduz um erro do catálogo em status HTTP e mensagem.
+func fruitTypeError(err error) (int, string) {
+	switch {
+	case errors.Is(err, models.ErrFruitTypeNotFound):
+		return http.StatusNotFound, "Tipo de fruta não encontrado"
+	case errors.Is(err, models.ErrFruitTypeNameTaken):
+		return http.StatusConflict, "Já existe um tipo de fruta com este nome"
+	case errors.Is(err, models.ErrFruitTypeInUse):
+		return http.StatusConflict, "Não é possível excluir um tipo de fruta que ainda tem frutas"
+	default:
+		return http.StatusInternalServerError, "Erro ao acessar o catálogo de tipos de fruta"
+	}
+}
+
+// applyFruitType completa o payload com os valores padrão do tipo informado
+// em "type_id", se houver.
+func (s *Server) applyFruitType(payload *models.CreateFruitRequest) error {
+	if payload.TypeID == nil {
+		return nil
+	}
+
+	fruitType, err := s.FruitTypes.GetFruitType(*payload.TypeID)
+	if err != nil {
+		return err
+	}
+
+	payload.ApplyType(fruitType)
+
+	return nil
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -12,31 +14,25 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/mr-utzig/planne-test/database"
 	"github.com/mr-utzig/planne-test/models"
+	"github.com/mr-utzig/planne-test/webhook"
 )
 
-var r *chi.Mux
+var (
+	r  chi.Router
+	db *sql.DB
+)
 
 // TestMain é executado antes de todos os testes neste pacote.
 // É usado para configurar o ambiente de teste (banco de dados em memória)
 // e limpar após a execução.
 func TestMain(m *testing.M) {
 	// Configura o banco de dados em memória para os testes
-	database.DB, _ = database.InitDBTest()
-	defer database.DB.Close()
+	db, _ = database.InitDBTest()
+	defer db.Close()
 
 	// Configura o roteador com as mesmas rotas da aplicação principal
-	r = chi.NewRouter()
-	r.Route("/buckets", func(r chi.Router) {
-		r.Post("/", CreateBucket)
-		r.Get("/", ListBuckets)
-		r.Delete("/{bucketID}", DeleteBucket)
-		r.Post("/{bucketID}/fruits", DepositFruit)
-		r.Delete("/{bucketID}/fruits/{fruitID}", RemoveFruitFromBucket)
-	})
-	r.Route("/fruits", func(r chi.Router) {
-		r.Post("/", CreateFruit)
-		r.Delete("/{fruitID}", DeleteFruit)
-	})
+	store := database.NewSQLiteStore(db)
+	r = NewServer(store, store, store, store).Routes()
 
 	// Executa os testes
 	exitCode := m.Run()
@@ -49,10 +45,17 @@ func TestMain(m *testing.M) {
 
 // clearTables limpa todas as tabelas para garantir que os testes sejam independentes.
 func clearTables() {
-	database.DB.Exec("DELETE FROM fruits")
-	database.DB.Exec("DELETE FROM buckets")
-	database.DB.Exec("UPDATE sqlite_sequence SET seq = 0 WHERE name = 'fruits'")
-	database.DB.Exec("UPDATE sqlite_sequence SET seq = 0 WHERE name = 'buckets'")
+	db.Exec("DELETE FROM expired_fruits")
+	db.Exec("DELETE FROM fruits")
+	db.Exec("DELETE FROM buckets")
+	db.Exec("DELETE FROM fruit_types")
+	db.Exec("DELETE FROM webhook_deliveries")
+	db.Exec("DELETE FROM webhooks")
+	db.Exec("UPDATE sqlite_sequence SET seq = 0 WHERE name = 'fruits'")
+	db.Exec("UPDATE sqlite_sequence SET seq = 0 WHERE name = 'buckets'")
+	db.Exec("UPDATE sqlite_sequence SET seq = 0 WHERE name = 'fruit_types'")
+	db.Exec("UPDATE sqlite_sequence SET seq = 0 WHERE name = 'webhooks'")
+	db.Exec("UPDATE sqlite_sequence SET seq = 0 WHERE name = 'webhook_deliveries'")
 }
 
 // executeRequest é uma função auxiliar para executar requisições HTTP contra o nosso roteador de teste.
@@ -94,7 +97,7 @@ func TestCreateBucket(t *testing.T) {
 func TestCreateFruit(t *testing.T) {
 	clearTables()
 
-	payload := []byte(`{"name": "Test Fruit", "price": 9.99, "expires_in_seconds": 60}`)
+	payload := []byte(`{"name": "Test Fruit", "price": "9.99", "expires_in_seconds": 60}`)
 	req, _ := http.NewRequest("POST", "/fruits", bytes.NewBuffer(payload))
 	response := executeRequest(req)
 
@@ -106,8 +109,64 @@ func TestCreateFruit(t *testing.T) {
 	if fruit.Name != "Test Fruit" {
 		t.Errorf("Expected fruit name to be 'Test Fruit'. Got '%s'", fruit.Name)
 	}
-	if fruit.Price != 9.99 {
-		t.Errorf("Expected fruit price to be 9.99. Got %v", fruit.Price)
+	if fruit.Price.String() != "9.99" || fruit.Price.Currency != "BRL" {
+		t.Errorf("Expected fruit price to be 9.99 BRL. Got %+v", fruit.Price)
+	}
+}
+
+// TestCreateFruitsBatch verifica a criação de frutas em lote, com e sem balde.
+func TestCreateFruitsBatch(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 1)")
+
+	// O segundo item é inválido, então o lote é recusado antes de chegar ao store.
+	payload := []byte(`[
+		{"name": "Apple", "price": "1.5", "expires_in_seconds": 60, "bucket_id": 1},
+		{"name": "", "price": "1.0", "expires_in_seconds": 60},
+		{"name": "Pear", "price": "2.0", "expires_in_seconds": 60, "bucket_id": 1}
+	]`)
+	req, _ := http.NewRequest("POST", "/fruits/batch", bytes.NewBuffer(payload))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	var failure struct {
+		Errors []models.BatchItemError `json:"errors"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &failure)
+	if len(failure.Errors) != 1 || failure.Errors[0].Index != 1 {
+		t.Errorf("Expected a validation error for item 1. Got %+v", failure.Errors)
+	}
+
+	// Corrigido o item inválido, sobra a falta de espaço no balde.
+	payload = bytes.Replace(payload, []byte(`"name": ""`), []byte(`"name": "Orange"`), 1)
+	req, _ = http.NewRequest("POST", "/fruits/batch", bytes.NewBuffer(payload))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	failure.Errors = nil
+	json.Unmarshal(response.Body.Bytes(), &failure)
+	if len(failure.Errors) != 1 || failure.Errors[0].Index != 2 || failure.Errors[0].Error != "Capacidade máxima do balde atingida" {
+		t.Errorf("Expected a capacity error for item 2. Got %+v", failure.Errors)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM fruits").Scan(&count)
+	if count != 0 {
+		t.Fatalf("Expected rejected batches to create nothing. Got %d fruits", count)
+	}
+
+	payload = bytes.Replace(payload, []byte(`"price": "2.0", "expires_in_seconds": 60, "bucket_id": 1`), []byte(`"price": "2.0", "expires_in_seconds": 60`), 1)
+	req, _ = http.NewRequest("POST", "/fruits/batch", bytes.NewBuffer(payload))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var fruits []models.Fruit
+	json.Unmarshal(response.Body.Bytes(), &fruits)
+	if len(fruits) != 3 || fruits[0].ID == 0 || !fruits[0].BucketID.Valid || fruits[2].BucketID.Valid {
+		t.Errorf("Expected 3 fruits with only the first in a bucket. Got %+v", fruits)
 	}
 }
 
@@ -115,9 +174,9 @@ func TestCreateFruit(t *testing.T) {
 func TestDepositFruitInBucket(t *testing.T) {
 	clearTables()
 	// 1. Cria um balde
-	database.DB.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
 	// 2. Cria uma fruta
-	database.DB.Exec("INSERT INTO fruits (id, name, price, expiration_time) VALUES (1, 'Apple', 1.0, ?)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (1, 'Apple', 100, ?)", time.Now().Add(1*time.Hour).Unix())
 
 	payload := []byte(`{"fruit_id": 1}`)
 	req, _ := http.NewRequest("POST", "/buckets/1/fruits", bytes.NewBuffer(payload))
@@ -127,7 +186,7 @@ func TestDepositFruitInBucket(t *testing.T) {
 
 	// Verifica no DB
 	var bucketID int
-	err := database.DB.QueryRow("SELECT bucket_id FROM fruits WHERE id = 1").Scan(&bucketID)
+	err := db.QueryRow("SELECT bucket_id FROM fruits WHERE id = 1").Scan(&bucketID)
 	if err != nil || bucketID != 1 {
 		t.Errorf("Expected fruit to be in bucket 1. Got error: %v or wrong bucketID: %d", err, bucketID)
 	}
@@ -137,10 +196,10 @@ func TestDepositFruitInBucket(t *testing.T) {
 func TestDepositFruitInFullBucket(t *testing.T) {
 	clearTables()
 	// 1. Cria um balde com capacidade 1
-	database.DB.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 1)")
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 1)")
 	// 2. Cria duas frutas
-	database.DB.Exec("INSERT INTO fruits (id, name, price, expiration_time, bucket_id) VALUES (1, 'Apple', 1.0, ?, 1)", time.Now().Add(1*time.Hour).Unix())
-	database.DB.Exec("INSERT INTO fruits (id, name, price, expiration_time) VALUES (2, 'Orange', 1.2, ?)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (2, 'Orange', 120, ?)", time.Now().Add(1*time.Hour).Unix())
 
 	// 3. Tenta depositar a segunda fruta
 	payload := []byte(`{"fruit_id": 2}`)
@@ -150,12 +209,107 @@ func TestDepositFruitInFullBucket(t *testing.T) {
 	checkResponseCode(t, http.StatusBadRequest, response.Code)
 }
 
+// TestDepositFruitOverWeightLimit verifica os limites de peso e volume do
+// balde no depósito e nos detalhes.
+func TestDepositFruitOverWeightLimit(t *testing.T) {
+	clearTables()
+	payload := []byte(`{"capacity": 10, "max_weight": 2, "max_volume": 4}`)
+	req, _ := http.NewRequest("POST", "/buckets", bytes.NewBuffer(payload))
+	checkResponseCode(t, http.StatusCreated, executeRequest(req).Code)
+
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, weight, volume) VALUES (1, 'Melon', 100, ?, 1.5, 1)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, weight) VALUES (2, 'Pumpkin', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+
+	req, _ = http.NewRequest("POST", "/buckets/1/fruits", bytes.NewBufferString(`{"fruit_id": 1}`))
+	checkResponseCode(t, http.StatusOK, executeRequest(req).Code)
+
+	req, _ = http.NewRequest("POST", "/buckets/1/fruits", bytes.NewBufferString(`{"fruit_id": 2}`))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	var body map[string]string
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if body["error"] != "Peso máximo do balde excedido" {
+		t.Errorf("Expected weight limit error. Got %q", body["error"])
+	}
+
+	req, _ = http.NewRequest("GET", "/buckets/1", nil)
+	response = executeRequest(req)
+
+	var details models.BucketDetails
+	json.Unmarshal(response.Body.Bytes(), &details)
+	if details.WeightOccupancy == nil || *details.WeightOccupancy != 75 || details.BindingConstraint != models.DimensionWeight {
+		t.Errorf("Expected weight as binding constraint at 75%%. Got %+v", details)
+	}
+
+	for _, payload := range []string{`{"capacity": 1, "max_weight": 0}`, `{"capacity": 1, "max_volume": -1}`} {
+		req, _ = http.NewRequest("POST", "/buckets", bytes.NewBufferString(payload))
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req).Code)
+	}
+}
+
+// TestDepositFruitsBatch verifica o depósito em lote nos dois modos.
+func TestDepositFruitsBatch(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 2)")
+	for id := 1; id <= 3; id++ {
+		db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (?, 'Apple', 100, ?)", id, time.Now().Add(1*time.Hour).Unix())
+	}
+
+	// Três frutas não cabem em um balde de capacidade 2: nada é depositado.
+	payload := []byte(`{"fruit_ids": [1, 2, 3]}`)
+	req, _ := http.NewRequest("POST", "/buckets/1/fruits", bytes.NewBuffer(payload))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM fruits WHERE bucket_id = 1").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected rejected batch to deposit nothing. Got %d fruits", count)
+	}
+
+	payload = []byte(`{"fruit_ids": [99, 1, 2, 3], "mode": "best_effort"}`)
+	req, _ = http.NewRequest("POST", "/buckets/1/fruits", bytes.NewBuffer(payload))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var body struct {
+		Deposited int                    `json:"deposited"`
+		Results   []models.DepositResult `json:"results"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if body.Deposited != 2 || len(body.Results) != 4 {
+		t.Fatalf("Expected 2 of 4 fruits deposited. Got %+v", body)
+	}
+	for i, want := range []bool{false, true, true, false} {
+		if body.Results[i].Deposited != want {
+			t.Errorf("Result %d: Expected deposited=%v. Got %+v", i, want, body.Results[i])
+		}
+	}
+	if body.Results[0].Error != "Fruta não encontrada" {
+		t.Errorf("Expected missing fruit error. Got %q", body.Results[0].Error)
+	}
+	if body.Results[3].Error != "Capacidade máxima do balde atingida" {
+		t.Errorf("Expected full bucket error. Got %q", body.Results[3].Error)
+	}
+
+	for _, payload := range []string{`{"fruit_ids": []}`, `{"fruit_ids": [1], "mode": "whatever"}`} {
+		req, _ = http.NewRequest("POST", "/buckets/1/fruits", bytes.NewBufferString(payload))
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req).Code)
+	}
+
+	req, _ = http.NewRequest("POST", "/buckets/99/fruits", bytes.NewBufferString(`{"fruit_ids": [1]}`))
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req).Code)
+}
+
 // TestRemoveFruitFromBucket verifica se uma fruta pode ser removida de um balde.
 func TestRemoveFruitFromBucket(t *testing.T) {
 	clearTables()
 	// 1. Cria um balde e uma fruta já dentro dele
-	database.DB.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
-	database.DB.Exec("INSERT INTO fruits (id, name, price, expiration_time, bucket_id) VALUES (1, 'Apple', 1.0, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
 
 	req, _ := http.NewRequest("DELETE", "/buckets/1/fruits/1", nil)
 	response := executeRequest(req)
@@ -164,7 +318,7 @@ func TestRemoveFruitFromBucket(t *testing.T) {
 
 	// Verifica no DB
 	var bucketID *int
-	err := database.DB.QueryRow("SELECT bucket_id FROM fruits WHERE id = 1").Scan(&bucketID)
+	err := db.QueryRow("SELECT bucket_id FROM fruits WHERE id = 1").Scan(&bucketID)
 	if err != nil {
 		t.Errorf("Expected bucket_id to be NULL, but got error: %v", err)
 	}
@@ -177,8 +331,8 @@ func TestRemoveFruitFromBucket(t *testing.T) {
 func TestDeleteNonEmptyBucket(t *testing.T) {
 	clearTables()
 	// 1. Cria um balde e uma fruta dentro dele
-	database.DB.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
-	database.DB.Exec("INSERT INTO fruits (id, name, price, expiration_time, bucket_id) VALUES (1, 'Apple', 1.0, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
 
 	req, _ := http.NewRequest("DELETE", "/buckets/1", nil)
 	response := executeRequest(req)
@@ -190,10 +344,904 @@ func TestDeleteNonEmptyBucket(t *testing.T) {
 func TestDeleteEmptyBucket(t *testing.T) {
 	clearTables()
 	// 1. Cria um balde vazio
-	database.DB.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
 
 	req, _ := http.NewRequest("DELETE", "/buckets/1", nil)
 	response := executeRequest(req)
 
 	checkResponseCode(t, http.StatusNoContent, response.Code)
 }
+
+// TestListExpiredFruits verifica a listagem do arquivo de frutas expiradas
+// com filtro por período.
+func TestListExpiredFruits(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO expired_fruits (id, name, price_minor, expiration_time, bucket_id, expired_at) VALUES (1, 'Apple', 100, 100, 1, 101)")
+	db.Exec("INSERT INTO expired_fruits (id, name, price_minor, expiration_time, bucket_id, expired_at) VALUES (2, 'Orange', 120, 200, NULL, 201)")
+
+	req, _ := http.NewRequest("GET", "/fruits/expired?from=150", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var fruits []models.ExpiredFruit
+	json.Unmarshal(response.Body.Bytes(), &fruits)
+
+	if len(fruits) != 1 || fruits[0].ID != 2 {
+		t.Errorf("Expected only fruit 2 in range. Got %+v", fruits)
+	}
+
+	req, _ = http.NewRequest("GET", "/fruits/expired?from=300&to=100", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+// TestGetBucket verifica os detalhes de um balde individual.
+func TestGetBucket(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 4)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 150, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (2, 'Orange', 250, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+
+	req, _ := http.NewRequest("GET", "/buckets/1", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var details models.BucketDetails
+	json.Unmarshal(response.Body.Bytes(), &details)
+
+	if len(details.Fruits) != 2 || details.TotalValue.Get("BRL").String() != "4.00" || details.Occupancy != 50 {
+		t.Errorf("Expected 2 fruits, total 4 and 50%% occupancy. Got %+v", details)
+	}
+
+	req, _ = http.NewRequest("GET", "/buckets/2", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+// TestGetFruit verifica os detalhes de uma fruta individual.
+func TestGetFruit(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 4)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 150, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+
+	req, _ := http.NewRequest("GET", "/fruits/1", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var details models.FruitDetails
+	json.Unmarshal(response.Body.Bytes(), &details)
+
+	if details.ExpiresInSeconds <= 3500 || details.ExpiresInSeconds > 3600 {
+		t.Errorf("Expected about 3600 seconds remaining. Got %d", details.ExpiresInSeconds)
+	}
+	if details.Bucket == nil || details.Bucket.ID != 1 {
+		t.Errorf("Expected fruit to report bucket 1. Got %+v", details.Bucket)
+	}
+
+	req, _ = http.NewRequest("GET", "/fruits/2", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+// TestListFruits verifica a listagem de frutas com filtros e ordenação.
+func TestListFruits(t *testing.T) {
+	clearTables()
+	expiration := time.Now().Add(1 * time.Hour).Unix()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 100, ?, 1)", expiration)
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (2, 'Orange', 300, ?)", expiration)
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (3, 'Banana', 200, ?)", expiration)
+
+	req, _ := http.NewRequest("GET", "/fruits?in_bucket=false&sort=price&order=desc", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var fruits []models.Fruit
+	json.Unmarshal(response.Body.Bytes(), &fruits)
+
+	if len(fruits) != 2 || fruits[0].ID != 2 || fruits[1].ID != 3 {
+		t.Errorf("Expected loose fruits [2 3] sorted by price desc. Got %+v", fruits)
+	}
+
+	for _, query := range []string{"in_bucket=maybe", "sort=color", "limit=0", "min_price=5&max_price=1"} {
+		req, _ := http.NewRequest("GET", "/fruits?"+query, nil)
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+// TestFruitPriceCurrencies verifica preços em outras moedas, a recusa de
+// preços numéricos e os filtros por moeda.
+func TestFruitPriceCurrencies(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5), (2, 5)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 900, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+
+	payload := []byte(`{"name": "Mango", "price": {"amount": "2.50", "currency": "USD"}, "expires_in_seconds": 60, "bucket_id": 2}`)
+	req, _ := http.NewRequest("POST", "/fruits", bytes.NewBuffer(payload))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var fruit models.Fruit
+	json.Unmarshal(response.Body.Bytes(), &fruit)
+	if fruit.Price != (models.Money{Amount: 250, Currency: "USD"}) {
+		t.Errorf("Expected 2.50 USD. Got %+v", fruit.Price)
+	}
+
+	for _, body := range []string{
+		`{"name": "Kiwi", "price": 2.5, "expires_in_seconds": 60}`,
+		`{"name": "Kiwi", "price": "2.505", "expires_in_seconds": 60}`,
+		`{"name": "Kiwi", "price": {"amount": "1", "currency": "XYZ"}, "expires_in_seconds": 60}`,
+		`{"name": "Kiwi", "price": "92233720368547758.07", "expires_in_seconds": 60}`,
+	} {
+		req, _ := http.NewRequest("POST", "/fruits", bytes.NewBufferString(body))
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+		var errBody map[string]string
+		json.Unmarshal(response.Body.Bytes(), &errBody)
+		if errBody["error"] == "Payload inválido" {
+			t.Errorf("Expected a price error for %s. Got %q", body, errBody["error"])
+		}
+	}
+
+	req, _ = http.NewRequest("GET", "/fruits?currency=USD&min_price=1", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var fruits []models.Fruit
+	json.Unmarshal(response.Body.Bytes(), &fruits)
+	if len(fruits) != 1 || fruits[0].ID != fruit.ID {
+		t.Errorf("Expected only the USD fruit. Got %+v", fruits)
+	}
+
+	req, _ = http.NewRequest("GET", "/buckets?sort=total_value&order=desc&currency=USD", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var page struct {
+		Buckets []models.BucketDetails `json:"buckets"`
+	}
+	json.Unmarshal(response.Body.Bytes(), &page)
+	if len(page.Buckets) != 2 || page.Buckets[0].ID != 2 || page.Buckets[1].TotalValue.Get("BRL").String() != "9.00" {
+		t.Errorf("Expected bucket 2 first by USD value. Got %+v", page.Buckets)
+	}
+
+	for _, query := range []string{"/fruits?currency=XYZ", "/fruits?min_price=1.001", "/buckets?sort=total_value&currency=XYZ"} {
+		req, _ := http.NewRequest("GET", query, nil)
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+// TestMarkdownPrices verifica os preços efetivos das frutas próximas da
+// expiração e o valor total efetivo do balde.
+func TestMarkdownPrices(t *testing.T) {
+	clearTables()
+	now := time.Now()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 1000, ?, 1)", now.Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (2, 'Orange', 1000, ?, 1)", now.Add(12*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (3, 'Pear', 1000, ?, 1)", now.Add(48*time.Hour).Unix())
+
+	store := database.NewSQLiteStore(db)
+	server := NewServer(store, store, store, store)
+	server.Markdown = models.Markdown{{Within: 24 * time.Hour, PercentOff: 20}, {Within: 6 * time.Hour, PercentOff: 50}}
+	router := server.Routes()
+
+	req, _ := http.NewRequest("GET", "/buckets/1", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var details models.BucketDetails
+	json.Unmarshal(response.Body.Bytes(), &details)
+
+	want := map[int]string{1: "5.00", 2: "8.00", 3: "10.00"}
+	for _, f := range details.Fruits {
+		if f.Price.String() != "10.00" || f.EffectivePrice.String() != want[f.ID] {
+			t.Errorf("Fruit %d: Expected 10.00 and %s. Got %s and %s", f.ID, want[f.ID], f.Price, f.EffectivePrice)
+		}
+	}
+	if details.TotalValue.Get("BRL").String() != "30.00" || details.EffectiveTotalValue.Get("BRL").String() != "23.00" {
+		t.Errorf("Expected totals 30.00 and 23.00. Got %v and %v", details.TotalValue, details.EffectiveTotalValue)
+	}
+
+	req, _ = http.NewRequest("GET", "/fruits/1", nil)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+
+	var body map[string]json.RawMessage
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if string(body["base_price"]) != `{"amount":"10.00","currency":"BRL"}` || string(body["effective_price"]) != `{"amount":"5.00","currency":"BRL"}` {
+		t.Errorf("Expected base and effective prices. Got %s", response.Body.String())
+	}
+
+	// Sem regras, o preço efetivo é o próprio preço base.
+	req, _ = http.NewRequest("GET", "/fruits/1", nil)
+	response = executeRequest(req)
+
+	var fruit models.FruitDetails
+	json.Unmarshal(response.Body.Bytes(), &fruit)
+	if fruit.EffectivePrice != fruit.Price {
+		t.Errorf("Expected the base price without markdown rules. Got %+v", fruit)
+	}
+}
+
+// TestForecast verifica a projeção de um balde e a projeção global.
+func TestForecast(t *testing.T) {
+	clearTables()
+	now := time.Now()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5), (2, 5)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 150, ?, 1)", now.Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (2, 'Orange', 250, ?, 1)", now.Add(72*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (3, 'Pear', 100, ?, 2)", now.Add(2*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (4, 'Loose', 900, ?)", now.Add(1*time.Hour).Unix())
+
+	req, _ := http.NewRequest("GET", "/buckets/1/forecast?horizon=48h", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var forecast struct {
+		BucketID int `json:"bucket_id"`
+		models.Forecast
+	}
+	json.Unmarshal(response.Body.Bytes(), &forecast)
+
+	last := forecast.Points[len(forecast.Points)-1]
+	if forecast.BucketID != 1 || len(forecast.Points) != 3 || forecast.Points[0].FruitCount != 2 || last.FruitCount != 1 ||
+		last.TotalValue.Get("BRL").String() != "2.50" || forecast.LostValue.Get("BRL").String() != "1.50" {
+		t.Errorf("Unexpected bucket forecast %+v", forecast)
+	}
+
+	req, _ = http.NewRequest("GET", "/buckets/forecast?horizon=48h&step=12h", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var global struct {
+		BucketCount int `json:"bucket_count"`
+		models.Forecast
+	}
+	json.Unmarshal(response.Body.Bytes(), &global)
+
+	if global.BucketCount != 2 || len(global.Points) != 5 || global.Points[0].FruitCount != 3 || global.Points[1].FruitCount != 1 ||
+		global.ExpiringCount != 2 || global.LostValue.Get("BRL").String() != "2.50" {
+		t.Errorf("Unexpected global forecast %+v", global)
+	}
+
+	for _, query := range []string{"/buckets/1/forecast?horizon=soon", "/buckets/1/forecast?horizon=-1h", "/buckets/forecast?horizon=1000h", "/buckets/forecast?horizon=48h&step=1s"} {
+		req, _ := http.NewRequest("GET", query, nil)
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/buckets/99/forecast", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+// TestExpiringFruits verifica a listagem de frutas prestes a expirar e o
+// aviso de expiração configurado em cada balde.
+func TestExpiringFruits(t *testing.T) {
+	clearTables()
+	now := time.Now()
+	db.Exec("INSERT INTO buckets (id, capacity, expiry_warning_seconds) VALUES (1, 5, 7200)")
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (2, 5)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 100, ?, 1)", now.Add(90*time.Minute).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (2, 'Orange', 100, ?, 1)", now.Add(30*time.Minute).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (3, 'Pear', 100, ?, 1)", now.Add(5*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (4, 'Apple', 100, ?)", now.Add(10*time.Minute).Unix())
+
+	req, _ := http.NewRequest("GET", "/fruits/expiring", nil)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var fruits []models.Fruit
+	json.Unmarshal(response.Body.Bytes(), &fruits)
+	if len(fruits) != 2 || fruits[0].ID != 4 || fruits[1].ID != 2 {
+		t.Errorf("Expected fruits [4 2] within 1h. Got %+v", fruits)
+	}
+
+	req, _ = http.NewRequest("GET", "/fruits/expiring?within=2h&name=Apple", nil)
+	response = executeRequest(req)
+
+	json.Unmarshal(response.Body.Bytes(), &fruits)
+	if len(fruits) != 2 || fruits[0].ID != 4 || fruits[1].ID != 1 {
+		t.Errorf("Expected apples [4 1] within 2h. Got %+v", fruits)
+	}
+
+	req, _ = http.NewRequest("GET", "/fruits/expiring?within=soon", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	req, _ = http.NewRequest("GET", "/buckets/1", nil)
+	response = executeRequest(req)
+
+	var details models.BucketDetails
+	json.Unmarshal(response.Body.Bytes(), &details)
+	if details.ExpiringCount != 2 || details.EarliestExpiration == nil || *details.EarliestExpiration != now.Add(30*time.Minute).Unix() {
+		t.Errorf("Expected 2 expiring fruits and the earliest expiration. Got %+v", details)
+	}
+
+	// Sem aviso configurado, nenhuma fruta é contada.
+	req, _ = http.NewRequest("GET", "/buckets/2", nil)
+	response = executeRequest(req)
+
+	details = models.BucketDetails{}
+	json.Unmarshal(response.Body.Bytes(), &details)
+	if details.ExpiringCount != 0 || details.EarliestExpiration != nil {
+		t.Errorf("Expected no expiring fruits in an empty bucket. Got %+v", details)
+	}
+
+	req, _ = http.NewRequest("PATCH", "/buckets/1", bytes.NewBuffer([]byte(`{"expiry_warning_seconds": 600}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("GET", "/buckets/1", nil)
+	response = executeRequest(req)
+
+	details = models.BucketDetails{}
+	json.Unmarshal(response.Body.Bytes(), &details)
+	if details.ExpiringCount != 0 || details.ExpiryWarningSeconds == nil || *details.ExpiryWarningSeconds != 600 {
+		t.Errorf("Expected a 600s warning with no expiring fruits. Got %+v", details)
+	}
+
+	req, _ = http.NewRequest("PATCH", "/buckets/1", bytes.NewBuffer([]byte(`{"expiry_warning_seconds": 0}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var warning sql.NullInt64
+	db.QueryRow("SELECT expiry_warning_seconds FROM buckets WHERE id = 1").Scan(&warning)
+	if warning.Valid {
+		t.Errorf("Expected the warning to be cleared. Got %d", warning.Int64)
+	}
+
+	// Uma capacidade recusada não deixa o aviso aplicado pela metade.
+	req, _ = http.NewRequest("PATCH", "/buckets/1", bytes.NewBuffer([]byte(`{"capacity": 1, "expiry_warning_seconds": 600}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	db.QueryRow("SELECT expiry_warning_seconds FROM buckets WHERE id = 1").Scan(&warning)
+	if warning.Valid {
+		t.Errorf("Expected the warning to stay cleared. Got %d", warning.Int64)
+	}
+
+	for _, payload := range []string{`{}`, `{"expiry_warning_seconds": -5}`} {
+		req, _ = http.NewRequest("PATCH", "/buckets/1", bytes.NewBuffer([]byte(payload)))
+		response = executeRequest(req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+}
+
+// TestListBucketsPagination verifica a paginação por cursor da listagem de
+// baldes e o envelope da resposta.
+func TestListBucketsPagination(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 2), (2, 4), (3, 1)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+
+	var ids []int
+	url := "/buckets?sort=capacity&order=asc&limit=2"
+	for page := 0; url != ""; page++ {
+		if page > 3 {
+			t.Fatalf("Expected pagination to end")
+		}
+
+		req, _ := http.NewRequest("GET", url, nil)
+		response := executeRequest(req)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var body models.BucketPage
+		json.Unmarshal(response.Body.Bytes(), &body)
+
+		for _, b := range body.Buckets {
+			ids = append(ids, b.ID)
+		}
+
+		url = ""
+		if body.NextCursor != nil {
+			url = "/buckets?sort=capacity&order=asc&limit=2&cursor=" + *body.NextCursor
+		}
+	}
+
+	if len(ids) != 3 || ids[0] != 3 || ids[1] != 1 || ids[2] != 2 {
+		t.Errorf("Expected buckets [3 1 2] by capacity. Got %v", ids)
+	}
+
+	// Um cursor não pode ser reaproveitado com outra ordenação
+	req, _ := http.NewRequest("GET", "/buckets?sort=capacity&order=asc&limit=1", nil)
+	response := executeRequest(req)
+
+	var body models.BucketPage
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if body.NextCursor == nil {
+		t.Fatalf("Expected a next cursor")
+	}
+
+	req, _ = http.NewRequest("GET", "/buckets?sort=id&cursor="+*body.NextCursor, nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
+
+// TestUpdateBucketCapacity verifica a alteração de capacidade e a recusa de
+// valores menores que a quantidade de frutas.
+func TestUpdateBucketCapacity(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (2, 'Orange', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+
+	req, _ := http.NewRequest("PATCH", "/buckets/1", bytes.NewBuffer([]byte(`{"capacity": 1}`)))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusConflict, response.Code)
+
+	req, _ = http.NewRequest("PATCH", "/buckets/1", bytes.NewBuffer([]byte(`{"capacity": 2}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var capacity int
+	db.QueryRow("SELECT capacity FROM buckets WHERE id = 1").Scan(&capacity)
+	if capacity != 2 {
+		t.Errorf("Expected capacity to be 2. Got %d", capacity)
+	}
+}
+
+// TestUpdateFruit verifica a correção de preço e a extensão da expiração.
+func TestUpdateFruit(t *testing.T) {
+	clearTables()
+	expiration := time.Now().Add(1 * time.Hour).Unix()
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (1, 'Apple', 1000, ?)", expiration)
+
+	payload := []byte(`{"price": "1.0", "extend_expiration_seconds": 600}`)
+	req, _ := http.NewRequest("PATCH", "/fruits/1", bytes.NewBuffer(payload))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var fruit models.Fruit
+	json.Unmarshal(response.Body.Bytes(), &fruit)
+
+	if fruit.Name != "Apple" || fruit.Price.String() != "1.00" || fruit.ExpirationTime != expiration+600 {
+		t.Errorf("Expected price 1.0 and expiration extended by 600s. Got %+v", fruit)
+	}
+
+	req, _ = http.NewRequest("PATCH", "/fruits/1", bytes.NewBuffer([]byte(`{"price": "-1"}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	req, _ = http.NewRequest("PATCH", "/fruits/1", bytes.NewBuffer([]byte(`{"extend_expiration_seconds": 9223372036854775000}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	// Extensões válidas que passariam do limite guardado também são recusadas.
+	db.Exec("UPDATE fruits SET expiration_time = ? WHERE id = 1", models.MaxExpirationTime-10)
+	req, _ = http.NewRequest("PATCH", "/fruits/1", bytes.NewBuffer([]byte(`{"extend_expiration_seconds": 60}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	req, _ = http.NewRequest("GET", "/fruits/1", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	req, _ = http.NewRequest("PATCH", "/fruits/2", bytes.NewBuffer([]byte(`{"name": "Pear"}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+// TestMoveFruit verifica a transferência de uma fruta entre baldes.
+func TestMoveFruit(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 5), (2, 1)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (2, 'Orange', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+
+	req, _ := http.NewRequest("POST", "/buckets/1/fruits/1/move", bytes.NewBuffer([]byte(`{"target_bucket_id": 2}`)))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var bucketID int
+	db.QueryRow("SELECT bucket_id FROM fruits WHERE id = 1").Scan(&bucketID)
+	if bucketID != 2 {
+		t.Errorf("Expected fruit to be in bucket 2. Got %d", bucketID)
+	}
+
+	// O balde 2 está cheio agora
+	req, _ = http.NewRequest("POST", "/buckets/1/fruits/2/move", bytes.NewBuffer([]byte(`{"target_bucket_id": 2}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+
+	db.QueryRow("SELECT bucket_id FROM fruits WHERE id = 2").Scan(&bucketID)
+	if bucketID != 1 {
+		t.Errorf("Expected fruit to stay in bucket 1. Got %d", bucketID)
+	}
+
+	// A fruta 1 não está mais no balde 1
+	req, _ = http.NewRequest("POST", "/buckets/1/fruits/1/move", bytes.NewBuffer([]byte(`{"target_bucket_id": 3}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+
+	// Uma fruta inexistente é 404 mesmo com o destino cheio
+	req, _ = http.NewRequest("POST", "/buckets/1/fruits/999/move", bytes.NewBuffer([]byte(`{"target_bucket_id": 2}`)))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusNotFound, response.Code)
+}
+
+// TestPlaceFruit verifica a alocação automática de uma fruta solta.
+func TestPlaceFruit(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 2), (2, 4)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (1, 'Apple', 100, ?, 1)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (2, 'Orange', 100, ?)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (3, 'Pear', 100, ?)", time.Now().Add(1*time.Hour).Unix())
+
+	// O balde 2 é o mais vazio, então worst_fit o escolhe.
+	req, _ := http.NewRequest("POST", "/fruits/2/place", bytes.NewBufferString(`{"strategy": "worst_fit"}`))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var details models.FruitDetails
+	json.Unmarshal(response.Body.Bytes(), &details)
+	if details.Bucket == nil || details.Bucket.ID != 2 {
+		t.Errorf("Expected fruit placed in bucket 2. Got %+v", details.Bucket)
+	}
+
+	// Sem corpo, vale a estratégia padrão (first_fit).
+	req, _ = http.NewRequest("POST", "/fruits/3/place", http.NoBody)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	json.Unmarshal(response.Body.Bytes(), &details)
+	if details.Bucket == nil || details.Bucket.ID != 1 {
+		t.Errorf("Expected fruit placed in bucket 1. Got %+v", details.Bucket)
+	}
+
+	req, _ = http.NewRequest("POST", "/fruits/3/place", http.NoBody)
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req).Code)
+
+	req, _ = http.NewRequest("POST", "/fruits/2/place", bytes.NewBufferString(`{"strategy": "random"}`))
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req).Code)
+
+	req, _ = http.NewRequest("POST", "/fruits/99/place", http.NoBody)
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req).Code)
+}
+
+// TestRebalanceBuckets verifica o plano de rebalanceamento, com e sem dry_run.
+func TestRebalanceBuckets(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 4), (2, 4)")
+	for id := 1; id <= 4; id++ {
+		db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time, bucket_id) VALUES (?, 'Apple', 100, ?, 1)", id, time.Now().Add(1*time.Hour).Unix())
+	}
+
+	var plan struct {
+		Moves  []models.FruitMove `json:"moves"`
+		DryRun bool               `json:"dry_run"`
+	}
+	countIn := func(bucketID int) int {
+		var count int
+		db.QueryRow("SELECT COUNT(*) FROM fruits WHERE bucket_id = ?", bucketID).Scan(&count)
+		return count
+	}
+
+	req, _ := http.NewRequest("POST", "/buckets/rebalance?dry_run=true", http.NoBody)
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	json.Unmarshal(response.Body.Bytes(), &plan)
+	if len(plan.Moves) != 2 || !plan.DryRun {
+		t.Errorf("Expected a dry run plan with 2 moves. Got %+v", plan)
+	}
+	if countIn(1) != 4 {
+		t.Errorf("Expected dry run to leave bucket 1 untouched. Got %d fruits", countIn(1))
+	}
+
+	req, _ = http.NewRequest("POST", "/buckets/rebalance", http.NoBody)
+	checkResponseCode(t, http.StatusOK, executeRequest(req).Code)
+
+	if countIn(1) != 2 || countIn(2) != 2 {
+		t.Errorf("Expected 2 fruits per bucket. Got %d and %d", countIn(1), countIn(2))
+	}
+
+	// Consolidar junta tudo de volta em um balde.
+	req, _ = http.NewRequest("POST", "/buckets/rebalance", bytes.NewBufferString(`{"target": "consolidate"}`))
+	checkResponseCode(t, http.StatusOK, executeRequest(req).Code)
+
+	if countIn(1)+countIn(2) != 4 || countIn(1) != 0 && countIn(2) != 0 {
+		t.Errorf("Expected all fruits in one bucket. Got %d and %d", countIn(1), countIn(2))
+	}
+
+	req, _ = http.NewRequest("POST", "/buckets/rebalance", bytes.NewBufferString(`{"target": "shuffle"}`))
+	checkResponseCode(t, http.StatusBadRequest, executeRequest(req).Code)
+}
+
+// TestFruitTypes verifica o catálogo de tipos e a criação de frutas a partir
+// dele.
+func TestFruitTypes(t *testing.T) {
+	clearTables()
+
+	payload := []byte(`{"name": "Banana", "shelf_life_seconds": 432000, "default_price": "0.75"}`)
+	req, _ := http.NewRequest("POST", "/fruit-types", bytes.NewBuffer(payload))
+	response := executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var banana models.FruitType
+	json.Unmarshal(response.Body.Bytes(), &banana)
+	if banana.ID != 1 || banana.Name != "Banana" {
+		t.Fatalf("Expected banana type with ID 1. Got %+v", banana)
+	}
+
+	req, _ = http.NewRequest("POST", "/fruit-types", bytes.NewBufferString(`{"name": "banana", "shelf_life_seconds": 60, "default_price": "1"}`))
+	checkResponseCode(t, http.StatusConflict, executeRequest(req).Code)
+
+	// Validades acima de 100 anos estourariam o cálculo da expiração.
+	for _, tt := range []struct{ method, url, body string }{
+		{"POST", "/fruit-types", `{"name": "Forever", "shelf_life_seconds": 20000000000, "default_price": "1"}`},
+		{"PATCH", "/fruit-types/1", `{"shelf_life_seconds": 20000000000}`},
+		{"POST", "/fruits", `{"name": "Forever", "price": "1", "expires_in_seconds": 20000000000}`},
+	} {
+		req, _ = http.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(req).Code)
+	}
+
+	// Sem sobrescritas, a fruta herda nome, preço e validade do tipo.
+	before := time.Now().Unix()
+	req, _ = http.NewRequest("POST", "/fruits", bytes.NewBufferString(`{"type_id": 1}`))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var fruit models.Fruit
+	json.Unmarshal(response.Body.Bytes(), &fruit)
+	if fruit.Name != "Banana" || fruit.Price.String() != "0.75" || fruit.ExpirationTime < before+432000 || fruit.TypeID == nil || *fruit.TypeID != 1 {
+		t.Errorf("Expected fruit with the type defaults. Got %+v", fruit)
+	}
+
+	req, _ = http.NewRequest("POST", "/fruits", bytes.NewBufferString(`{"type_id": 1, "name": "Banana prata", "price": "1.1"}`))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	json.Unmarshal(response.Body.Bytes(), &fruit)
+	if fruit.Name != "Banana prata" || fruit.Price.String() != "1.10" {
+		t.Errorf("Expected overridden name and price. Got %+v", fruit)
+	}
+
+	req, _ = http.NewRequest("POST", "/fruits", bytes.NewBufferString(`{"type_id": 99}`))
+	checkResponseCode(t, http.StatusNotFound, executeRequest(req).Code)
+
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (10, 'Apple', 200, ?)", time.Now().Add(1*time.Hour).Unix())
+
+	req, _ = http.NewRequest("GET", "/fruits?group_by=type", nil)
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var groups []models.FruitGroup
+	json.Unmarshal(response.Body.Bytes(), &groups)
+	if len(groups) != 2 || groups[0].Type == nil || groups[0].FruitCount != 2 || groups[1].Type != nil || groups[1].FruitCount != 1 {
+		t.Errorf("Expected a banana group with 2 fruits and an untyped group with 1. Got %+v", groups)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/fruit-types/1", nil)
+	checkResponseCode(t, http.StatusConflict, executeRequest(req).Code)
+
+	req, _ = http.NewRequest("PATCH", "/fruit-types/1", bytes.NewBufferString(`{"default_price": "0.9"}`))
+	response = executeRequest(req)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	json.Unmarshal(response.Body.Bytes(), &banana)
+	if banana.DefaultPrice.String() != "0.90" || banana.ShelfLifeSeconds != 432000 {
+		t.Errorf("Expected only the default price to change. Got %+v", banana)
+	}
+}
+
+// recordingPublisher guarda os eventos publicados pelos handlers.
+type recordingPublisher struct {
+	events []string
+	data   []any
+}
+
+func (p *recordingPublisher) Publish(event string, data any) {
+	p.events = append(p.events, event)
+	p.data = append(p.data, data)
+}
+
+// TestWebhookEvents verifica os eventos publicados pelas operações com
+// baldes e frutas.
+func TestWebhookEvents(t *testing.T) {
+	clearTables()
+	db.Exec("INSERT INTO buckets (id, capacity) VALUES (1, 2), (2, 5)")
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (1, 'Apple', 100, ?)", time.Now().Add(1*time.Hour).Unix())
+	db.Exec("INSERT INTO fruits (id, name, price_minor, expiration_time) VALUES (2, 'Orange', 100, ?)", time.Now().Add(1*time.Hour).Unix())
+
+	store := database.NewSQLiteStore(db)
+	server := NewServer(store, store, store, store)
+	events := &recordingPublisher{}
+	server.Events = events
+	router := server.Routes()
+
+	requests := []struct {
+		method, url, body string
+		want              []string
+	}{
+		{"POST", "/fruits", `{"name": "Pear", "price": "1.00", "expires_in_seconds": 3600}`, []string{models.EventFruitCreated}},
+		{"POST", "/buckets/1/fruits", `{"fruit_id": 1}`, []string{models.EventFruitDeposited}},
+		{"POST", "/buckets/1/fruits", `{"fruit_ids": [2]}`, []string{models.EventFruitDeposited, models.EventBucketFull}},
+		{"DELETE", "/buckets/1/fruits/2", "", []string{models.EventFruitRemoved}},
+		{"POST", "/buckets/1/fruits/1/move", `{"target_bucket_id": 2}`, []string{models.EventFruitRemoved, models.EventFruitDeposited}},
+		{"POST", "/buckets/1/fruits", `{"fruit_id": 99}`, nil},
+		{"DELETE", "/buckets/1", "", []string{models.EventBucketDeleted}},
+		{"DELETE", "/buckets/1", "", nil},
+	}
+
+	for _, tt := range requests {
+		events.events, events.data = nil, nil
+
+		req, _ := http.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+
+		if len(events.events) != len(tt.want) {
+			t.Errorf("%s %s: Expected events %v. Got %v", tt.method, tt.url, tt.want, events.events)
+			continue
+		}
+		for i := range tt.want {
+			if events.events[i] != tt.want[i] {
+				t.Errorf("%s %s: Expected events %v. Got %v", tt.method, tt.url, tt.want, events.events)
+				break
+			}
+		}
+	}
+
+	events.events, events.data = nil, nil
+	req, _ := http.NewRequest("POST", "/buckets/2/fruits", bytes.NewBufferString(`{"fruit_id": 2}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(events.data) != 1 {
+		t.Fatalf("Expected one event. Got %v", events.events)
+	}
+	if data, ok := events.data[0].(models.FruitBucketEvent); !ok || data.BucketID != 2 || data.Fruit.ID != 2 || !data.Fruit.BucketID.Valid {
+		t.Errorf("Expected fruit 2 deposited in bucket 2. Got %+v", events.data[0])
+	}
+}
+
+// TestWebhooks verifica o cadastro de webhooks e a entrega assinada de um
+// evento a um receptor de teste, com o registro da entrega.
+func TestWebhooks(t *testing.T) {
+	clearTables()
+
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+
+	store := database.NewSQLiteStore(db)
+	server := NewServer(store, store, store, store)
+	dispatcher := webhook.NewDispatcher(store)
+	defer dispatcher.Close()
+	server.Events = dispatcher
+	router := server.Routes()
+
+	for _, payload := range []string{
+		`{"url": "ftp://example.com", "events": ["fruit.created"]}`,
+		`{"url": "http://example.com"}`,
+		`{"url": "http://example.com", "events": ["fruit.eaten"]}`,
+	} {
+		req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBufferString(payload))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+
+		checkResponseCode(t, http.StatusBadRequest, response.Code)
+	}
+
+	payload := `{"url": "` + receiver.URL + `", "events": ["fruit.created", "fruit.created"], "secret": "s3cret"}`
+	req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBufferString(payload))
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+
+	checkResponseCode(t, http.StatusCreated, response.Code)
+
+	var hook models.Webhook
+	json.Unmarshal(response.Body.Bytes(), &hook)
+	if hook.ID != 1 || hook.Secret != "s3cret" || len(hook.Events) != 1 {
+		t.Errorf("Expected webhook 1 with one event and its secret. Got %+v", hook)
+	}
+
+	req, _ = http.NewRequest("GET", "/webhooks", nil)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+
+	var hooks []models.Webhook
+	json.Unmarshal(response.Body.Bytes(), &hooks)
+	if len(hooks) != 1 || hooks[0].Secret != "" {
+		t.Errorf("Expected one webhook without its secret. Got %+v", hooks)
+	}
+
+	req, _ = http.NewRequest("POST", "/fruits", bytes.NewBufferString(`{"name": "Apple", "price": "1.50", "expires_in_seconds": 3600}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		if !webhook.Verify("s3cret", body, r.Header.Get(webhook.SignatureHeader)) || r.Header.Get(webhook.EventHeader) != models.EventFruitCreated {
+			t.Errorf("Expected a signed fruit.created delivery. Got %v %s", r.Header, body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected the receiver to get the event")
+	}
+
+	var deliveries []models.WebhookDelivery
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		req, _ = http.NewRequest("GET", "/webhooks/1/deliveries", nil)
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+
+		json.Unmarshal(response.Body.Bytes(), &deliveries)
+		if len(deliveries) == 1 && deliveries[0].Status != models.DeliveryPending {
+			break
+		}
+	}
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryDelivered || deliveries[0].Attempts != 1 || deliveries[0].Event != models.EventFruitCreated {
+		t.Errorf("Expected one delivered delivery in the log. Got %+v", deliveries)
+	}
+
+	req, _ = http.NewRequest("DELETE", "/webhooks/1", nil)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+
+	checkResponseCode(t, http.StatusNoContent, response.Code)
+
+	for _, url := range []string{"/webhooks/1", "/webhooks/1/deliveries"} {
+		req, _ = http.NewRequest("GET", url, nil)
+		response = httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+
+		checkResponseCode(t, http.StatusNotFound, response.Code)
+	}
+}
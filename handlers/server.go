@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/mr-utzig/planne-test/models"
+	"github.com/mr-utzig/planne-test/placement"
+)
+
+// ExpirationScheduler é avisado quando frutas são criadas ou excluídas, para
+// que a remoção por expiração aconteça no momento certo.
+type ExpirationScheduler interface {
+	Schedule(fruitID int, expiresAt int64)
+	Cancel(fruitID int)
+}
+
+// Server agrupa os handlers da API e os stores dos quais eles dependem.
+type Server struct {
+	Buckets     models.BucketStore
+	Fruits      models.FruitStore
+	FruitTypes  models.FruitTypeStore
+	Webhooks    models.WebhookStore
+	Expirations ExpirationScheduler
+	// Events recebe os eventos publicados pelos handlers, como depósitos e
+	// remoções de frutas, para a entrega aos webhooks.
+	Events EventPublisher
+	// Placement é a estratégia usada na alocação automática quando a
+	// requisição não escolhe outra.
+	Placement placement.Strategy
+	// Markdown são as regras de remarcação aplicadas aos preços na leitura.
+	// Sem regras, o preço efetivo é o preço base.
+	Markdown models.Markdown
+}
+
+// NewServer cria um servidor a partir dos stores de baldes, frutas, tipos de
+// fruta e webhooks. O agendador de expirações começa vazio, os eventos são
+// descartados e a alocação usa first-fit; todos podem ser substituídos
+// depois.
+func NewServer(buckets models.BucketStore, fruits models.FruitStore, types models.FruitTypeStore, webhooks models.WebhookStore) *Server {
+	return &Server{
+		Buckets:     buckets,
+		Fruits:      fruits,
+		FruitTypes:  types,
+		Webhooks:    webhooks,
+		Expirations: noopScheduler{},
+		Events:      noopPublisher{},
+		Placement:   placement.FirstFit,
+	}
+}
+
+// noopScheduler ignora os avisos de expiração.
+type noopScheduler struct{}
+
+func (noopScheduler) Schedule(int, int64) {}
+
+func (noopScheduler) Cancel(int) {}
+
+// Routes monta o roteador com todas as rotas da API.
+func (s *Server) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Route("/buckets", func(r chi.Router) {
+		r.Get("/", s.ListBuckets)
+		r.Post("/", s.CreateBucket)
+		r.Post("/rebalance", s.RebalanceBuckets)
+		r.Get("/forecast", s.ForecastBuckets)
+		r.Get("/{bucketID}", s.GetBucket)
+		r.Patch("/{bucketID}", s.UpdateBucket)
+		r.Delete("/{bucketID}", s.DeleteBucket)
+		r.Get("/{bucketID}/forecast", s.ForecastBucket)
+
+		r.Post("/{bucketID}/fruits", s.DepositFruit)
+		r.Delete("/{bucketID}/fruits/{fruitID}", s.RemoveFruitFromBucket)
+		r.Post("/{bucketID}/fruits/{fruitID}/move", s.MoveFruit)
+	})
+
+	r.Route("/fruits", func(r chi.Router) {
+		r.Get("/", s.ListFruits)
+		r.Post("/", s.CreateFruit)
+		r.Post("/batch", s.CreateFruits)
+		r.Get("/expired", s.ListExpiredFruits)
+		r.Get("/expiring", s.ListExpiringFruits)
+		r.Get("/{fruitID}", s.GetFruit)
+		r.Patch("/{fruitID}", s.UpdateFruit)
+		r.Delete("/{fruitID}", s.DeleteFruit)
+		r.Post("/{fruitID}/place", s.PlaceFruit)
+	})
+
+	r.Route("/fruit-types", func(r chi.Router) {
+		r.Get("/", s.ListFruitTypes)
+		r.Post("/", s.CreateFruitType)
+		r.Get("/{typeID}", s.GetFruitType)
+		r.Patch("/{typeID}", s.UpdateFruitType)
+		r.Delete("/{typeID}", s.DeleteFruitType)
+	})
+
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Get("/", s.ListWebhooks)
+		r.Post("/", s.CreateWebhook)
+		r.Get("/{webhookID}", s.GetWebhook)
+		r.Delete("/{webhookID}", s.DeleteWebhook)
+		r.Get("/{webhookID}/deliveries", s.ListWebhookDeliveries)
+	})
+
+	return r
+}
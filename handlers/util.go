@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+
+	"github.com/mr-utzig/planne-test/models"
 )
 
 // respondWithError envia uma resposta de erro JSON padronizada.
@@ -17,3 +21,29 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.WriteHeader(code)
 	w.Write(response)
 }
+
+// queryInt64 lê um parâmetro inteiro opcional da query string. Um parâmetro
+// ausente vale zero.
+func queryInt64(r *http.Request, name string) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// positiveOrNil indica se um valor opcional está ausente ou é positivo.
+func positiveOrNil(value *float64) bool {
+	return value == nil || *value > 0
+}
+
+// payloadError descreve um erro de decodificação do corpo da requisição,
+// detalhando os erros de preço.
+func payloadError(err error) string {
+	if errors.Is(err, models.ErrInvalidAmount) || errors.Is(err, models.ErrUnknownCurrency) {
+		return "Preço inválido: use um texto decimal, como \"1.50\", de até 1000000000000 unidades menores da moeda, e uma moeda aceita"
+	}
+
+	return "Payload inválido"
+}
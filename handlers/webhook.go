@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mr-utzig/planne-test/models"
+	"github.com/mr-utzig/planne-test/webhook"
+)
+
+// CreateWebhook cadastra uma URL para receber os eventos informados. Sem
+// "secret", um segredo aleatório é gerado; ele só é devolvido nesta resposta.
+func (s *Server) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Payload inválido")
+		return
+	}
+
+	target, err := url.Parse(payload.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		respondWithError(w, http.StatusBadRequest, "Campo 'url' deve ser uma URL http ou https")
+		return
+	}
+
+	if len(payload.Events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Informe ao menos um evento em 'events'")
+		return
+	}
+
+	var events []string
+	for _, event := range payload.Events {
+		if !models.ValidWebhookEvent(event) {
+			respondWithError(w, http.StatusBadRequest, "Evento inválido: "+event+". Use "+strings.Join(models.WebhookEvents, ", "))
+			return
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+
+	if payload.Secret == "" {
+		if payload.Secret, err = webhook.NewSecret(); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Erro ao gerar o segredo do webhook")
+			return
+		}
+	}
+
+	hook := models.Webhook{URL: payload.URL, Events: events, Secret: payload.Secret, CreatedAt: time.Now().Unix()}
+	if err := s.Webhooks.CreateWebhook(&hook); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao cadastrar o webhook")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, hook)
+}
+
+// ListWebhooks lista os webhooks cadastrados, sem os segredos.
+func (s *Server) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := s.Webhooks.ListWebhooks()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar webhooks")
+		return
+	}
+
+	if hooks == nil {
+		hooks = []models.Webhook{}
+	}
+
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	respondWithJSON(w, http.StatusOK, hooks)
+}
+
+// GetWebhook retorna um webhook, sem o segredo.
+func (s *Server) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de webhook inválido")
+		return
+	}
+
+	hook, err := s.Webhooks.GetWebhook(webhookID)
+	if err != nil {
+		status, message := webhookError(err)
+		respondWithError(w, status, message)
+		return
+	}
+
+	hook.Secret = ""
+	respondWithJSON(w, http.StatusOK, hook)
+}
+
+// DeleteWebhook exclui um webhook e o registro das entregas dele. Entregas
+// em andamento são abandonadas.
+func (s *Server) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de webhook inválido")
+		return
+	}
+
+	if err := s.Webhooks.DeleteWebhook(webhookID); err != nil {
+		status, message := webhookError(err)
+		respondWithError(w, status, message)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries lista o registro das entregas de um webhook, da mais
+// recente para a mais antiga.
+func (s *Server) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookID, err := strconv.Atoi(chi.URLParam(r, "webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "ID de webhook inválido")
+		return
+	}
+
+	if _, err := s.Webhooks.GetWebhook(webhookID); err != nil {
+		status, message := webhookError(err)
+		respondWithError(w, status, message)
+		return
+	}
+
+	deliveries, err := s.Webhooks.ListDeliveries(webhookID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Erro ao buscar as entregas do webhook")
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveries)
+}
+
+// webhookError traduz um erro do store de webhooks em status HTTP e mensagem.
+func webhookError(err error) (int, string) {
+	if errors.Is(err, models.ErrWebhookNotFound) {
+		return http.StatusNotFound, "Webhook não encontrado"
+	}
+
+	return http.StatusInternalServerError, "Erro ao acessar os webhooks"
+}
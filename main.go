@@ -1,26 +1,111 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/mr-utzig/planne-test/config"
 	"github.com/mr-utzig/planne-test/database"
+	"github.com/mr-utzig/planne-test/database/memory"
+	"github.com/mr-utzig/planne-test/expiration"
 	"github.com/mr-utzig/planne-test/handlers"
+	"github.com/mr-utzig/planne-test/models"
+	"github.com/mr-utzig/planne-test/placement"
+	"github.com/mr-utzig/planne-test/webhook"
 )
 
+// appStore reúne as operações de persistência usadas pelo servidor,
+// implementadas tanto pelo store SQLite quanto pelo store em memória.
+type appStore interface {
+	models.BucketStore
+	models.FruitStore
+	models.FruitTypeStore
+	models.WebhookStore
+}
+
 func main() {
-	// Inicializa o banco de dados SQLite
-	if err := database.InitDB(); err != nil {
-		log.Fatalf("Falha ao inicializar o banco de dados: %v", err)
+	// Carrega a configuração de arquivo, variáveis de ambiente e flags
+	cfg, args, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	}
+
+	// Subcomando para gerenciar as migrações do schema
+	if len(args) > 0 && args[0] == "migrate" {
+		if cfg.Store == config.StoreMemory {
+			log.Fatalf("O subcomando migrate só se aplica ao store %s", config.StoreSQLite)
+		}
+		if err := runMigrate(cfg.DBPath, args[1:]); err != nil {
+			log.Fatalf("Erro ao executar migrações: %v", err)
+		}
+		return
+	}
+
+	log.Printf("Configuração efetiva: %s", cfg)
+
+	var store appStore
+	switch cfg.Store {
+	case config.StoreMemory:
+		log.Println("Usando o store em memória: os dados serão perdidos ao encerrar.")
+		store = memory.New()
+	default:
+		// Inicializa o banco de dados SQLite e aplica as migrações pendentes
+		db, err := database.InitDB(cfg.DBPath)
+		if err != nil {
+			log.Fatalf("Falha ao inicializar o banco de dados: %v", err)
+		}
+		// O banco só é fechado depois que o servidor e a rotina de limpeza
+		// pararam.
+		defer db.Close()
+
+		store = database.NewSQLiteStore(db)
 	}
-	defer database.DB.Close()
 
-	// Inicia a rotina em background para remover frutas expiradas
-	// a cada 1 segundo.
-	go handlers.StartExpirationJanitor(1 * time.Second)
+	server := handlers.NewServer(store, store, store, store)
+	// A configuração já foi validada, então a estratégia existe.
+	server.Placement, _ = placement.Lookup(cfg.PlacementStrategy)
+	server.Markdown = cfg.MarkdownRules
+
+	// Entrega os eventos aos webhooks, retomando as entregas que ficaram
+	// pendentes na última execução.
+	dispatcher := webhook.NewDispatcher(store)
+	if err := dispatcher.Load(); err != nil {
+		log.Fatalf("Falha ao carregar as entregas de webhooks: %v", err)
+	}
+	server.Events = dispatcher
+
+	// Carrega as expirações existentes para remover cada fruta no instante
+	// em que ela vence.
+	scheduler := expiration.NewScheduler(store, cfg.JanitorInterval)
+	if err := scheduler.Load(); err != nil {
+		log.Fatalf("Falha ao carregar as expirações: %v", err)
+	}
+	scheduler.OnExpire = func(fruits []models.ExpiredFruit) {
+		for _, fruit := range fruits {
+			dispatcher.Publish(models.EventFruitExpired, fruit)
+		}
+	}
+	server.Expirations = scheduler
+
+	// O contexto é cancelado ao receber SIGINT ou SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Inicia a rotina em background que remove as frutas expiradas
+	var janitor sync.WaitGroup
+	janitor.Add(1)
+	go func() {
+		defer janitor.Done()
+		scheduler.Run(ctx)
+	}()
 
 	// Configura o roteador Chi
 	r := chi.NewRouter()
@@ -30,24 +115,38 @@ func main() {
 	r.Use(middleware.Recoverer)
 
 	// Define as rotas da API
-	r.Route("/v1", func(r chi.Router) {
-		r.Route("/buckets", func(r chi.Router) {
-			r.Get("/", handlers.ListBuckets)
-			r.Post("/", handlers.CreateBucket)
-			r.Delete("/{bucketID}", handlers.DeleteBucket)
-
-			r.Post("/{bucketID}/fruits", handlers.DepositFruit)
-			r.Delete("/{bucketID}/fruits/{fruitID}", handlers.RemoveFruitFromBucket)
-		})
-
-		r.Route("/fruits", func(r chi.Router) {
-			r.Post("/", handlers.CreateFruit)
-			r.Delete("/{fruitID}", handlers.DeleteFruit)
-		})
-	})
-
-	log.Println("Servidor iniciado na porta :8080")
-	if err := http.ListenAndServe(":8080", r); err != nil {
-		log.Fatalf("Erro ao iniciar o servidor: %v", err)
+	r.Mount("/v1", server.Routes())
+
+	httpServer := &http.Server{Addr: cfg.ListenAddr, Handler: r}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Servidor iniciado em %s", cfg.ListenAddr)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Erro ao iniciar o servidor: %v", err)
+		}
+		stop()
+	case <-ctx.Done():
+		log.Println("Sinal recebido, encerrando o servidor...")
 	}
+
+	// Aguarda as requisições em andamento até o limite configurado
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Erro ao encerrar o servidor: %v", err)
+	}
+
+	janitor.Wait()
+
+	// As entregas interrompidas continuam pendentes e são retomadas no
+	// próximo início.
+	dispatcher.Close()
+	log.Println("Servidor encerrado.")
 }
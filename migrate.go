@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mr-utzig/planne-test/database"
+)
+
+// runMigrate implementa o subcomando `migrate status|up|down [passos]`.
+func runMigrate(dbPath string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("uso: migrate status|up|down [passos]")
+	}
+
+	db, err := database.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		states, err := database.Status(db)
+		if err != nil {
+			return err
+		}
+
+		for _, state := range states {
+			applied := "pendente"
+			if state.Applied {
+				applied = "aplicada em " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Printf("%04d_%s\t%s\n", state.Version, state.Name, applied)
+		}
+
+	case "up":
+		applied, err := database.MigrateUp(db)
+		for _, m := range applied {
+			fmt.Printf("Aplicada: %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			fmt.Println("Nenhuma migração pendente.")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("número de passos inválido: %s", args[1])
+			}
+		}
+
+		reverted, err := database.MigrateDown(db, steps)
+		for _, m := range reverted {
+			fmt.Printf("Revertida: %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(reverted) == 0 {
+			fmt.Println("Nenhuma migração aplicada.")
+		}
+
+	default:
+		return fmt.Errorf("subcomando desconhecido: %s (use status, up ou down)", args[0])
+	}
+
+	return nil
+}
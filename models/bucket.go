@@ -1,94 +1,316 @@
 package models
 
-import (
-	"log"
+import "time"
 
-	"github.com/mr-utzig/planne-test/database"
+// Bucket representa a estrutura de um balde no banco de dados. Os limites de
+// peso e volume são opcionais; nil significa sem limite.
+type Bucket struct {
+	ID        int      `json:"id"`
+	Capacity  int      `json:"capacity"`
+	MaxWeight *float64 `json:"max_weight"`
+	MaxVolume *float64 `json:"max_volume"`
+	// ExpiryWarningSeconds é o aviso de expiração: frutas que expiram em até
+	// esse tempo são contadas em BucketDetails.ExpiringCount. Nil desliga o
+	// aviso.
+	ExpiryWarningSeconds *int64 `json:"expiry_warning_seconds"`
+}
+
+// UpdateBucketRequest é a estrutura do corpo da requisição para alterar um
+// balde. Campos ausentes não são alterados; ExpiryWarningSeconds igual a zero
+// desliga o aviso de expiração.
+type UpdateBucketRequest struct {
+	Capacity             *int   `json:"capacity"`
+	ExpiryWarningSeconds *int64 `json:"expiry_warning_seconds"`
+}
+
+// Dimensões de capacidade de um balde.
+const (
+	DimensionCount  = "count"
+	DimensionWeight = "weight"
+	DimensionVolume = "volume"
 )
 
-// Bucket representa a estrutura de um balde no banco de dados.
-type Bucket struct {
-	ID       int `json:"id"`
-	Capacity int `json:"capacity"`
+// BucketLoad é a carga atual de um balde em cada dimensão. Frutas sem peso
+// ou volume contam como zero nessas dimensões.
+type BucketLoad struct {
+	Count  int
+	Weight float64
+	Volume float64
+}
+
+// Add soma a fruta à carga.
+func (l *BucketLoad) Add(fruit Fruit) {
+	l.Count++
+	l.Weight += valueOrZero(fruit.Weight)
+	l.Volume += valueOrZero(fruit.Volume)
+}
+
+// Remove subtrai a fruta da carga.
+func (l *BucketLoad) Remove(fruit Fruit) {
+	l.Count--
+	l.Weight -= valueOrZero(fruit.Weight)
+	l.Volume -= valueOrZero(fruit.Volume)
+}
+
+// CheckFit verifica se a fruta cabe no balde com a carga informada,
+// devolvendo ErrBucketFull, ErrWeightLimitExceeded ou ErrVolumeLimitExceeded
+// conforme o primeiro limite violado.
+func (b Bucket) CheckFit(load BucketLoad, fruit Fruit) error {
+	if load.Count >= b.Capacity {
+		return ErrBucketFull
+	}
+
+	if b.MaxWeight != nil && load.Weight+valueOrZero(fruit.Weight) > *b.MaxWeight {
+		return ErrWeightLimitExceeded
+	}
+
+	if b.MaxVolume != nil && load.Volume+valueOrZero(fruit.Volume) > *b.MaxVolume {
+		return ErrVolumeLimitExceeded
+	}
+
+	return nil
+}
+
+func valueOrZero(v *float64) float64 {
+	if v == nil {
+		return 0
+	}
+
+	return *v
 }
 
 // BucketDetails é uma estrutura mais completa usada para a listagem,
 // incluindo informações sobre as frutas contidas.
 type BucketDetails struct {
-	ID         int     `json:"id"`
-	Capacity   int     `json:"capacity"`
-	Fruits     []Fruit `json:"fruits"`
-	TotalValue float64 `json:"total_value"`
-	Occupancy  float64 `json:"occupancy_percentage"`
-}
-
-func (b *Bucket) Insert() error {
-	result, err := database.DB.Exec("INSERT INTO buckets (capacity) VALUES (?)", b.Capacity)
-	if err != nil {
-		log.Println(err)
-		return err
+	ID                   int      `json:"id"`
+	Capacity             int      `json:"capacity"`
+	MaxWeight            *float64 `json:"max_weight"`
+	MaxVolume            *float64 `json:"max_volume"`
+	ExpiryWarningSeconds *int64   `json:"expiry_warning_seconds"`
+	Fruits               []Fruit  `json:"fruits"`
+	FruitCount           int      `json:"fruit_count"`
+	// TotalValue soma os preços base das frutas separadamente por moeda.
+	TotalValue MoneyTotals `json:"total_value"`
+	// EffectiveTotalValue soma os preços efetivos, com a remarcação (ver
+	// Markdown.PriceBucket).
+	EffectiveTotalValue MoneyTotals `json:"effective_total_value"`
+	// Occupancy considera apenas a quantidade de frutas; é o campo usado
+	// para ordenar e filtrar a listagem.
+	Occupancy   float64 `json:"occupancy_percentage"`
+	TotalWeight float64 `json:"total_weight"`
+	TotalVolume float64 `json:"total_volume"`
+	// WeightOccupancy e VolumeOccupancy são nulos quando o balde não tem
+	// limite na dimensão.
+	WeightOccupancy *float64 `json:"weight_occupancy_percentage"`
+	VolumeOccupancy *float64 `json:"volume_occupancy_percentage"`
+	// BindingConstraint é a dimensão mais próxima do limite.
+	BindingConstraint string `json:"binding_constraint"`
+	// ExpiringCount é a quantidade de frutas dentro do aviso de expiração do
+	// balde e EarliestExpiration, a expiração mais próxima (nula se o balde
+	// estiver vazio). Ambos são calculados na leitura (ver CalcExpiring).
+	ExpiringCount      int    `json:"expiring_count"`
+	EarliestExpiration *int64 `json:"earliest_expiration"`
+}
+
+// Campos aceitos para ordenar a listagem de baldes.
+const (
+	BucketSortOccupancy  = "occupancy"
+	BucketSortTotalValue = "total_value"
+	BucketSortCapacity   = "capacity"
+	BucketSortID         = "id"
+)
+
+// BucketCursor identifica o último balde de uma página: o valor do campo de
+// ordenação e o ID, usado como desempate.
+type BucketCursor struct {
+	Value float64
+	ID    int
+}
+
+// BucketFilter descreve os filtros, a ordenação e a paginação por cursor da
+// listagem de baldes. O ID desempata na mesma direção da ordenação.
+type BucketFilter struct {
+	// SortBy vazio ordena pela ocupação.
+	SortBy string
+	Desc   bool
+	// Currency é a moeda do total usado na ordenação por valor; vazia vale
+	// DefaultCurrency.
+	Currency string
+
+	MinOccupancy float64
+	Empty        *bool
+	Full         *bool
+
+	// After, se informado, devolve apenas os baldes posteriores ao cursor.
+	After *BucketCursor
+	// Limit igual a zero devolve todos os baldes.
+	Limit int
+}
+
+// BucketPage é o envelope de resposta da listagem paginada de baldes.
+// NextCursor é nulo na última página.
+type BucketPage struct {
+	Buckets    []BucketDetails `json:"buckets"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+// NewBucketDetails monta os detalhes de um balde a partir das frutas que ele
+// contém, já calculando o valor total e a ocupação.
+func NewBucketDetails(bucket Bucket, fruits []Fruit) BucketDetails {
+	details := BucketDetails{
+		ID:                   bucket.ID,
+		Capacity:             bucket.Capacity,
+		MaxWeight:            bucket.MaxWeight,
+		MaxVolume:            bucket.MaxVolume,
+		Fruits:               fruits,
+		FruitCount:           len(fruits),
+		ExpiryWarningSeconds: bucket.ExpiryWarningSeconds,
 	}
 
-	id, _ := result.LastInsertId()
-	b.ID = int(id)
+	details.CalcTotalValue()
+	details.CalcOccupancyPercentage()
+	details.CalcDimensions()
 
-	return nil
+	return details
 }
 
-func (b *Bucket) GetByID(id int) error {
-	row := database.DB.QueryRow("SELECT id, capacity FROM buckets WHERE id = ?", id)
+// Bucket devolve o balde descrito pelos detalhes.
+func (d BucketDetails) Bucket() Bucket {
+	return Bucket{ID: d.ID, Capacity: d.Capacity, MaxWeight: d.MaxWeight, MaxVolume: d.MaxVolume, ExpiryWarningSeconds: d.ExpiryWarningSeconds}
+}
 
-	if err := row.Scan(&b.ID, &b.Capacity); err != nil {
-		log.Println(err)
-		return err
+// Load devolve a carga atual do balde.
+func (d BucketDetails) Load() BucketLoad {
+	return BucketLoad{Count: d.FruitCount, Weight: d.TotalWeight, Volume: d.TotalVolume}
+}
+
+// SortCurrency devolve a moeda usada na ordenação por valor.
+func (f BucketFilter) SortCurrency() string {
+	if f.Currency == "" {
+		return DefaultCurrency
 	}
 
-	return nil
+	return f.Currency
 }
 
-func (b Bucket) GetAll() ([]Bucket, error) {
-	rows, err := database.DB.Query("SELECT id, capacity FROM buckets")
-	if err != nil {
-		log.Println(err)
-		return nil, err
+// SortValue devolve o valor do campo de ordenação do filtro, usado para
+// comparar baldes e montar cursores. O valor total é a quantia, em unidades
+// menores, da moeda do filtro.
+func (d BucketDetails) SortValue(filter BucketFilter) float64 {
+	switch filter.SortBy {
+	case BucketSortTotalValue:
+		return float64(d.TotalValue[filter.SortCurrency()])
+	case BucketSortCapacity:
+		return float64(d.Capacity)
+	case BucketSortID:
+		return float64(d.ID)
+	default:
+		return d.Occupancy
 	}
-	defer rows.Close()
-
-	var buckets []Bucket
-	for rows.Next() {
-		var bucket Bucket
-		if err := rows.Scan(&bucket.ID, &bucket.Capacity); err != nil {
-			log.Println(err)
-			return nil, err
-		}
+}
+
+// Cursor devolve o cursor que aponta para este balde.
+func (d BucketDetails) Cursor(filter BucketFilter) BucketCursor {
+	return BucketCursor{Value: d.SortValue(filter), ID: d.ID}
+}
 
-		buckets = append(buckets, bucket)
+// CalcTotalValue soma os preços das frutas, um total por moeda.
+func (d *BucketDetails) CalcTotalValue() {
+	d.TotalValue = MoneyTotals{}
+	for _, fruit := range d.Fruits {
+		d.TotalValue.Add(fruit.Price)
 	}
+}
 
-	return buckets, nil
+func (d *BucketDetails) CalcOccupancyPercentage() {
+	d.Occupancy = OccupancyPercentage(len(d.Fruits), d.Capacity)
 }
 
-func (b Bucket) DeleteByID(id int) error {
-	_, err := database.DB.Exec("DELETE FROM buckets WHERE id = ?", id)
-	if err != nil {
-		log.Println(err)
-		return err
+// CalcDimensions soma o peso e o volume das frutas, calcula a ocupação de
+// cada dimensão com limite e aponta a mais restritiva; em caso de empate
+// prevalece a quantidade, depois o peso.
+func (d *BucketDetails) CalcDimensions() {
+	d.TotalWeight, d.TotalVolume = 0, 0
+	for _, fruit := range d.Fruits {
+		d.TotalWeight += valueOrZero(fruit.Weight)
+		d.TotalVolume += valueOrZero(fruit.Volume)
 	}
 
-	return nil
+	d.WeightOccupancy = limitOccupancy(d.TotalWeight, d.MaxWeight)
+	d.VolumeOccupancy = limitOccupancy(d.TotalVolume, d.MaxVolume)
+
+	d.BindingConstraint = DimensionCount
+	highest := d.Occupancy
+	if d.WeightOccupancy != nil && *d.WeightOccupancy > highest {
+		d.BindingConstraint, highest = DimensionWeight, *d.WeightOccupancy
+	}
+	if d.VolumeOccupancy != nil && *d.VolumeOccupancy > highest {
+		d.BindingConstraint = DimensionVolume
+	}
 }
 
-func (d *BucketDetails) CalcTotalValue() {
-	total := 0.0
+// CalcExpiring conta as frutas que expiram dentro do aviso de expiração do
+// balde, a partir de now, e encontra a expiração mais próxima. Frutas já
+// vencidas que aguardam a remoção também são contadas.
+func (d *BucketDetails) CalcExpiring(now time.Time) {
+	d.ExpiringCount = 0
+	d.EarliestExpiration = nil
+
 	for _, fruit := range d.Fruits {
-		total += fruit.Price
+		if d.EarliestExpiration == nil || fruit.ExpirationTime < *d.EarliestExpiration {
+			expiration := fruit.ExpirationTime
+			d.EarliestExpiration = &expiration
+		}
+		if d.ExpiryWarningSeconds != nil && fruit.ExpirationTime-now.Unix() <= *d.ExpiryWarningSeconds {
+			d.ExpiringCount++
+		}
+	}
+}
+
+// limitOccupancy calcula a ocupação de uma dimensão, ou nil se ela não tiver
+// limite.
+func limitOccupancy(total float64, limit *float64) *float64 {
+	if limit == nil {
+		return nil
+	}
+
+	occupancy := 0.0
+	if *limit > 0 {
+		occupancy = total / *limit * 100
 	}
 
-	d.TotalValue = total
+	return &occupancy
 }
 
-func (d *BucketDetails) CalcOccupancyPercentage() {
-	if d.Capacity > 0 {
-		d.Occupancy = (float64(len(d.Fruits)) / float64(d.Capacity)) * 100
+// OccupancyPercentage calcula a ocupação de um balde. O store SQLite faz a
+// mesma conta, na mesma ordem, para que os resultados sejam idênticos.
+func OccupancyPercentage(fruitCount, capacity int) float64 {
+	if capacity <= 0 {
+		return 0
 	}
+
+	return (float64(fruitCount) / float64(capacity)) * 100
+}
+
+// Modos de depósito em lote.
+const (
+	// DepositModeAllOrNothing recusa o lote inteiro se alguma fruta falhar.
+	DepositModeAllOrNothing = "all_or_nothing"
+	// DepositModeBestEffort deposita as frutas que couberem.
+	DepositModeBestEffort = "best_effort"
+)
+
+// DepositResult é o resultado do depósito de uma fruta de um lote.
+type DepositResult struct {
+	FruitID   int    `json:"fruit_id"`
+	Deposited bool   `json:"deposited"`
+	Error     string `json:"error,omitempty"`
+}
+
+// FruitMove é a transferência de uma fruta entre dois baldes, como parte de
+// um plano de rebalanceamento.
+type FruitMove struct {
+	FruitID      int `json:"fruit_id"`
+	FromBucketID int `json:"from_bucket_id"`
+	ToBucketID   int `json:"to_bucket_id"`
 }
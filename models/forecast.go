@@ -0,0 +1,94 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// ForecastPoint é o estado previsto do estoque em um instante: as frutas que
+// ainda não expiraram e o valor total delas.
+type ForecastPoint struct {
+	Time       int64       `json:"time"`
+	FruitCount int         `json:"fruit_count"`
+	TotalValue MoneyTotals `json:"total_value"`
+}
+
+// Forecast é a projeção do estoque entre From e To conforme as frutas
+// expiram. ExpiringCount e LostValue são as frutas e o valor que expiram no
+// período.
+type Forecast struct {
+	From          int64           `json:"from"`
+	To            int64           `json:"to"`
+	Points        []ForecastPoint `json:"points"`
+	ExpiringCount int             `json:"expiring_count"`
+	LostValue     MoneyTotals     `json:"lost_value"`
+}
+
+// NewForecast projeta o estoque formado pelas frutas de now até now+horizon,
+// usando o preço base, como CalcTotalValue. Com step zero, há um ponto em now,
+// um a cada expiração no período e um no fim; senão, os pontos são espaçados
+// por step a partir de now. Frutas que já expiraram e ainda aguardam a
+// remoção não entram na projeção.
+func NewForecast(fruits []Fruit, now time.Time, horizon, step time.Duration) Forecast {
+	from, to := now.Unix(), now.Add(horizon).Unix()
+
+	var active []Fruit
+	current := MoneyTotals{}
+	for _, f := range fruits {
+		if f.ExpirationTime > from {
+			active = append(active, f)
+			current.Add(f.Price)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].ExpirationTime < active[j].ExpirationTime })
+
+	forecast := Forecast{From: from, To: to, LostValue: MoneyTotals{}}
+	point := func(t int64) ForecastPoint {
+		return ForecastPoint{Time: t, FruitCount: len(active) - forecast.ExpiringCount, TotalValue: current.Clone()}
+	}
+
+	forecast.Points = append(forecast.Points, point(from))
+	for _, t := range forecastTimes(active, from, to, step) {
+		for forecast.ExpiringCount < len(active) && active[forecast.ExpiringCount].ExpirationTime <= t {
+			f := active[forecast.ExpiringCount]
+			current.Sub(f.Price)
+			forecast.LostValue.Add(f.Price)
+			forecast.ExpiringCount++
+		}
+		forecast.Points = append(forecast.Points, point(t))
+	}
+
+	return forecast
+}
+
+// forecastTimes devolve os instantes dos pontos seguintes ao primeiro, em
+// ordem crescente e terminando em to. active deve estar ordenado pela
+// expiração.
+func forecastTimes(active []Fruit, from, to int64, step time.Duration) []int64 {
+	var times []int64
+	if step > 0 {
+		seconds := int64(step / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		for t := from + seconds; t < to; t += seconds {
+			times = append(times, t)
+		}
+	} else {
+		for _, f := range active {
+			t := f.ExpirationTime
+			if t >= to {
+				break
+			}
+			if len(times) == 0 || times[len(times)-1] != t {
+				times = append(times, t)
+			}
+		}
+	}
+
+	if to > from {
+		times = append(times, to)
+	}
+
+	return times
+}
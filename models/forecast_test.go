@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewForecast(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	at := func(d time.Duration) int64 { return now.Add(d).Unix() }
+
+	fruits := []Fruit{
+		{ID: 1, Price: Money{300, "BRL"}, ExpirationTime: at(2 * time.Hour)},
+		{ID: 2, Price: Money{100, "BRL"}, ExpirationTime: at(time.Hour)},
+		{ID: 3, Price: Money{200, "USD"}, ExpirationTime: at(time.Hour)},
+		{ID: 4, Price: Money{500, "BRL"}, ExpirationTime: at(72 * time.Hour)},
+		{ID: 5, Price: Money{900, "BRL"}, ExpirationTime: at(-time.Minute)},
+	}
+
+	forecast := NewForecast(fruits, now, 48*time.Hour, 0)
+
+	want := []struct {
+		time  int64
+		count int
+		brl   int64
+		usd   int64
+	}{
+		{at(0), 4, 900, 200},
+		{at(time.Hour), 2, 800, 0},
+		{at(2 * time.Hour), 1, 500, 0},
+		{at(48 * time.Hour), 1, 500, 0},
+	}
+
+	if len(forecast.Points) != len(want) {
+		t.Fatalf("Expected %d points. Got %+v", len(want), forecast.Points)
+	}
+	for i, w := range want {
+		p := forecast.Points[i]
+		if p.Time != w.time || p.FruitCount != w.count || p.TotalValue["BRL"] != w.brl || p.TotalValue["USD"] != w.usd {
+			t.Errorf("Point %d: Expected %+v. Got %+v", i, w, p)
+		}
+	}
+
+	if forecast.ExpiringCount != 3 || forecast.LostValue["BRL"] != 400 || forecast.LostValue["USD"] != 200 {
+		t.Errorf("Expected 3 fruits and 4.00 BRL + 2.00 USD lost. Got %d and %v", forecast.ExpiringCount, forecast.LostValue)
+	}
+	if forecast.From != at(0) || forecast.To != at(48*time.Hour) {
+		t.Errorf("Unexpected period %d-%d", forecast.From, forecast.To)
+	}
+}
+
+func TestNewForecastWithStep(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	fruits := []Fruit{
+		{ID: 1, Price: Money{100, "BRL"}, ExpirationTime: now.Add(90 * time.Minute).Unix()},
+	}
+
+	forecast := NewForecast(fruits, now, 3*time.Hour, time.Hour)
+
+	counts := []int{1, 1, 0, 0}
+	if len(forecast.Points) != len(counts) {
+		t.Fatalf("Expected %d points. Got %+v", len(counts), forecast.Points)
+	}
+	for i, count := range counts {
+		p := forecast.Points[i]
+		if p.Time != now.Add(time.Duration(i)*time.Hour).Unix() || p.FruitCount != count {
+			t.Errorf("Point %d: Expected %d fruits. Got %+v", i, count, p)
+		}
+	}
+
+	empty := NewForecast(nil, now, time.Hour, 0)
+	if len(empty.Points) != 2 || empty.Points[1].FruitCount != 0 || len(empty.LostValue) != 0 {
+		t.Errorf("Expected an empty forecast. Got %+v", empty)
+	}
+}
@@ -2,130 +2,161 @@ package models
 
 import (
 	"database/sql"
-	"log"
 	"time"
+)
 
-	"github.com/mr-utzig/planne-test/database"
+// Limites da expiração das frutas. MaxLifetimeSeconds limita os prazos
+// informados pelo cliente (100 anos) e MaxExpirationTime, o instante de
+// expiração guardado (fim do ano 9999), para que nenhuma soma estoure o int64.
+const (
+	MaxLifetimeSeconds int64 = 100 * 365 * 24 * 60 * 60
+	MaxExpirationTime  int64 = 253402300799
 )
 
-// Fruit representa a estrutura de uma fruta no banco de dados.
+// Fruit representa a estrutura de uma fruta no banco de dados. O peso, o
+// volume e o tipo do catálogo são opcionais.
 type Fruit struct {
-	ID             int           `json:"id"`
-	Name           string        `json:"name"`
-	Price          float64       `json:"price"`
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Price é o preço base, guardado no banco.
+	Price Money `json:"base_price"`
+	// EffectivePrice é o preço com a remarcação por proximidade da
+	// expiração. É calculado na leitura (ver Markdown) e não é guardado.
+	EffectivePrice Money         `json:"effective_price,omitzero"`
 	ExpirationTime int64         `json:"expiration_time"`
 	BucketID       sql.NullInt64 `json:"bucket_id"`
+	Weight         *float64      `json:"weight"`
+	Volume         *float64      `json:"volume"`
+	TypeID         *int          `json:"type_id"`
 }
 
-// CreateFruitRequest é a estrutura do corpo da requisição para criar uma nova fruta.
-// Usa `ExpiresInSeconds` para facilitar a entrada do usuário.
-type CreateFruitRequest struct {
-	Name             string  `json:"name"`
-	Price            float64 `json:"price"`
+// FruitDetails é a visão de uma fruta individual, com o tempo restante até a
+// expiração e o balde em que ela está, se houver.
+type FruitDetails struct {
+	Fruit
 	ExpiresInSeconds int64   `json:"expires_in_seconds"`
+	Bucket           *Bucket `json:"bucket"`
 }
 
-func (f *Fruit) GetByID(id int) error {
-	row := database.DB.QueryRow("SELECT id, name, price, expiration_time, bucket_id FROM fruits WHERE id = ?", id)
-
-	if err := row.Scan(&f.ID, &f.Name, &f.Price, &f.ExpirationTime, &f.BucketID); err != nil {
-		log.Println(err)
-		return err
+// NewFruitDetails monta os detalhes de uma fruta no instante `now`. O tempo
+// restante nunca é negativo.
+func NewFruitDetails(fruit Fruit, bucket *Bucket, now time.Time) FruitDetails {
+	remaining := fruit.ExpirationTime - now.Unix()
+	if remaining < 0 {
+		remaining = 0
 	}
 
-	return nil
+	return FruitDetails{Fruit: fruit, ExpiresInSeconds: remaining, Bucket: bucket}
 }
 
-func (f Fruit) GetFruitsInBucket(bucketID int) ([]Fruit, error) {
-	rows, err := database.DB.Query("SELECT id, name, price, expiration_time, bucket_id FROM fruits WHERE bucket_id = ?", bucketID)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-	defer rows.Close()
-
-	var fruits []Fruit
-	for rows.Next() {
-		var fruit Fruit
-		if err := rows.Scan(&fruit.ID, &fruit.Name, &fruit.Price, &fruit.ExpirationTime, &fruit.BucketID); err != nil {
-			log.Println(err)
-			return nil, err
-		}
-
-		fruits = append(fruits, fruit)
-	}
-
-	return fruits, nil
+// ExpiredFruit é uma fruta arquivada após expirar. Mantém o último balde em
+// que estava e o preço, para os relatórios de desperdício.
+type ExpiredFruit struct {
+	Fruit
+	ExpiredAt int64 `json:"expired_at"`
 }
 
-func (f *Fruit) AddToBucket(bucketID int) (int64, error) {
-	result, err := database.DB.Exec("UPDATE fruits SET bucket_id = ? WHERE id = ?", bucketID, f.ID)
-	if err != nil {
-		log.Println(err)
-		return 0, err
-	}
+// Campos aceitos para ordenar a listagem de frutas.
+const (
+	FruitSortID         = "id"
+	FruitSortPrice      = "price"
+	FruitSortName       = "name"
+	FruitSortExpiration = "expiration"
+)
 
-	return result.RowsAffected()
+// FruitFilter descreve os filtros, a ordenação e a paginação da listagem de
+// frutas. Campos com valor zero não filtram.
+type FruitFilter struct {
+	// InBucket filtra frutas dentro (true) ou fora (false) de baldes.
+	InBucket *bool
+	BucketID int
+	TypeID   int
+	// Name filtra pelas frutas cujo nome contém o texto, sem diferenciar
+	// maiúsculas de minúsculas.
+	Name string
+	// Currency filtra pela moeda do preço. MinPrice e MaxPrice são quantias
+	// em unidades menores dessa moeda e só valem junto com ela.
+	Currency      string
+	MinPrice      int64
+	MaxPrice      int64
+	ExpiresBefore int64
+	ExpiresAfter  int64
+
+	SortBy string
+	Desc   bool
+	// Limit igual a zero devolve todas as frutas.
+	Limit  int
+	Offset int
+}
 
+// CreateFruitRequest é a estrutura do corpo da requisição para criar uma nova fruta.
+// Usa `ExpiresInSeconds` para facilitar a entrada do usuário. Com TypeID, os
+// campos não informados vêm do tipo de fruta (ver ApplyType).
+type CreateFruitRequest struct {
+	Name             string   `json:"name"`
+	Price            *Money   `json:"price"`
+	ExpiresInSeconds int64    `json:"expires_in_seconds"`
+	Weight           *float64 `json:"weight"`
+	Volume           *float64 `json:"volume"`
+	TypeID           *int     `json:"type_id"`
 }
 
-func (f Fruit) RemoveFromBucket(fruitID, bucketID int) (int64, error) {
-	result, err := database.DB.Exec("UPDATE fruits SET bucket_id = NULL WHERE id = ? AND bucket_id = ?", fruitID, bucketID)
-	if err != nil {
-		log.Println(err)
-		return 0, err
+// ApplyType preenche o nome, o preço e a expiração que não foram informados
+// com os valores padrão do tipo. Os valores informados têm precedência.
+func (f *CreateFruitRequest) ApplyType(t FruitType) {
+	if f.Name == "" {
+		f.Name = t.Name
+	}
+	if f.Price == nil {
+		price := t.DefaultPrice
+		f.Price = &price
+	}
+	if f.ExpiresInSeconds == 0 {
+		f.ExpiresInSeconds = t.ShelfLifeSeconds
 	}
-
-	return result.RowsAffected()
 }
 
-func (f Fruit) DeleteByID(id int) error {
-	_, err := database.DB.Exec("DELETE FROM fruits WHERE id = ?", id)
-	if err != nil {
-		log.Println(err)
-		return err
+// ToFruit converte o payload em uma fruta, calculando o instante de expiração
+// a partir do momento atual. O preço já deve ter sido validado.
+func (f CreateFruitRequest) ToFruit() Fruit {
+	return Fruit{
+		Name:           f.Name,
+		Price:          *f.Price,
+		ExpirationTime: time.Now().Unix() + f.ExpiresInSeconds,
+		Weight:         f.Weight,
+		Volume:         f.Volume,
+		TypeID:         f.TypeID,
 	}
-
-	return nil
 }
 
-func (f Fruit) DeleteExpireds() int64 {
-	now := time.Now().Unix()
-
-	result, err := database.DB.Exec("DELETE FROM fruits WHERE expiration_time <= ?", now)
-	if err != nil {
-		log.Println("Erro ao limpar frutas expiradas:", err)
-		return 0
-	}
+// BatchFruitRequest é um item da criação de frutas em lote. Quando BucketID é
+// informado, a fruta já é criada dentro do balde.
+type BatchFruitRequest struct {
+	CreateFruitRequest
+	BucketID *int `json:"bucket_id"`
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Println("Erro ao obter linhas afetadas pela limpeza:", err)
-		return 0
+// ToFruit converte o item em uma fruta, já apontando para o balde informado.
+func (f BatchFruitRequest) ToFruit() Fruit {
+	fruit := f.CreateFruitRequest.ToFruit()
+	if f.BucketID != nil {
+		fruit.BucketID = sql.NullInt64{Int64: int64(*f.BucketID), Valid: true}
 	}
 
-	return rowsAffected
+	return fruit
 }
 
-func (f *CreateFruitRequest) InsertFruitFromPayload() (*Fruit, error) {
-	expirationTime := time.Now().Add(time.Duration(f.ExpiresInSeconds) * time.Second).Unix()
-
-	result, err := database.DB.Exec(
-		"INSERT INTO fruits (name, price, expiration_time) VALUES (?, ?, ?)",
-		f.Name, f.Price, expirationTime,
-	)
-	if err != nil {
-		log.Println(err)
-		return nil, err
-	}
-
-	id, _ := result.LastInsertId()
-	fruit := &Fruit{
-		ID:             int(id),
-		Name:           f.Name,
-		Price:          f.Price,
-		ExpirationTime: expirationTime,
-	}
+// BatchItemError descreve a falha de um item de uma operação em lote pela
+// sua posição no payload.
+type BatchItemError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
 
-	return fruit, nil
+// UpdateFruitRequest é a estrutura do corpo da requisição para alterar uma
+// fruta. Campos ausentes não são alterados; a expiração só pode ser estendida.
+type UpdateFruitRequest struct {
+	Name                    *string `json:"name"`
+	Price                   *Money  `json:"price"`
+	ExtendExpirationSeconds int64   `json:"extend_expiration_seconds"`
 }
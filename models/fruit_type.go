@@ -0,0 +1,85 @@
+package models
+
+import "sort"
+
+// FruitType é um item do catálogo de tipos de fruta, com os valores padrão
+// usados na criação de frutas daquele tipo.
+type FruitType struct {
+	ID               int    `json:"id"`
+	Name             string `json:"name"`
+	ShelfLifeSeconds int64  `json:"shelf_life_seconds"`
+	DefaultPrice     Money  `json:"default_price"`
+}
+
+// UpdateFruitTypeRequest é a estrutura do corpo da requisição para alterar um
+// tipo de fruta. Campos ausentes não são alterados.
+type UpdateFruitTypeRequest struct {
+	Name             *string `json:"name"`
+	ShelfLifeSeconds *int64  `json:"shelf_life_seconds"`
+	DefaultPrice     *Money  `json:"default_price"`
+}
+
+// Apply devolve o tipo com as alterações da requisição.
+func (u UpdateFruitTypeRequest) Apply(t FruitType) FruitType {
+	if u.Name != nil {
+		t.Name = *u.Name
+	}
+	if u.ShelfLifeSeconds != nil {
+		t.ShelfLifeSeconds = *u.ShelfLifeSeconds
+	}
+	if u.DefaultPrice != nil {
+		t.DefaultPrice = *u.DefaultPrice
+	}
+
+	return t
+}
+
+// FruitGroup reúne as frutas de um mesmo tipo na listagem agrupada. Type é
+// nil no grupo das frutas sem tipo.
+type FruitGroup struct {
+	Type       *FruitType  `json:"type"`
+	FruitCount int         `json:"fruit_count"`
+	TotalValue MoneyTotals `json:"total_value"`
+	Fruits     []Fruit     `json:"fruits"`
+}
+
+// GroupFruitsByType agrupa as frutas pelo tipo, mantendo a ordem delas dentro
+// de cada grupo. Os grupos seguem o ID do tipo e as frutas sem tipo (ou de um
+// tipo ausente de types) ficam no último grupo.
+func GroupFruitsByType(fruits []Fruit, types []FruitType) []FruitGroup {
+	byID := make(map[int]FruitType, len(types))
+	for _, t := range types {
+		byID[t.ID] = t
+	}
+
+	index := make(map[int]int)
+	groups := []FruitGroup{}
+	untyped := FruitGroup{TotalValue: MoneyTotals{}, Fruits: []Fruit{}}
+
+	for _, f := range fruits {
+		group := &untyped
+		if f.TypeID != nil {
+			if t, ok := byID[*f.TypeID]; ok {
+				i, seen := index[t.ID]
+				if !seen {
+					i = len(groups)
+					index[t.ID] = i
+					groups = append(groups, FruitGroup{Type: &t, TotalValue: MoneyTotals{}, Fruits: []Fruit{}})
+				}
+				group = &groups[i]
+			}
+		}
+
+		group.Fruits = append(group.Fruits, f)
+		group.FruitCount++
+		group.TotalValue.Add(f.Price)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Type.ID < groups[j].Type.ID })
+
+	if untyped.FruitCount > 0 {
+		groups = append(groups, untyped)
+	}
+
+	return groups
+}
@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarkdownRule é uma regra de remarcação: frutas que expiram dentro da
+// janela Within recebem PercentOff por cento de desconto.
+type MarkdownRule struct {
+	Within     time.Duration `yaml:"within"`
+	PercentOff int64         `yaml:"percent_off"`
+}
+
+// Markdown é o conjunto de regras de remarcação. Quando mais de uma regra
+// alcança a expiração da fruta, vale a de menor janela, a mais próxima do
+// vencimento. Sem regras, o preço efetivo é o preço base.
+type Markdown []MarkdownRule
+
+// ParseMarkdown lê as regras no formato "janela:percentual" separadas por
+// vírgula, como "24h:20,6h:50". Um texto vazio não tem regras.
+func ParseMarkdown(s string) (Markdown, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var m Markdown
+	for _, part := range strings.Split(s, ",") {
+		within, percent, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("regra de remarcação %q deve ter o formato janela:percentual", part)
+		}
+
+		d, err := time.ParseDuration(within)
+		if err != nil {
+			return nil, fmt.Errorf("janela da regra de remarcação %q inválida: %w", part, err)
+		}
+
+		p, err := strconv.ParseInt(percent, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("percentual da regra de remarcação %q inválido: %w", part, err)
+		}
+
+		m = append(m, MarkdownRule{Within: d, PercentOff: p})
+	}
+
+	return m, m.Validate()
+}
+
+// Validate verifica se as janelas são positivas e distintas e se os
+// percentuais vão de 1 a 100.
+func (m Markdown) Validate() error {
+	var errs []error
+	seen := make(map[time.Duration]bool, len(m))
+	for _, r := range m {
+		if r.Within <= 0 {
+			errs = append(errs, fmt.Errorf("janela da regra de remarcação deve ser positiva: %s", r.Within))
+		}
+		if r.PercentOff < 1 || r.PercentOff > 100 {
+			errs = append(errs, fmt.Errorf("percentual da regra de remarcação deve ir de 1 a 100: %d", r.PercentOff))
+		}
+		if seen[r.Within] {
+			errs = append(errs, fmt.Errorf("janela da regra de remarcação repetida: %s", r.Within))
+		}
+		seen[r.Within] = true
+	}
+
+	return errors.Join(errs...)
+}
+
+// String formata as regras no formato aceito por ParseMarkdown, da maior
+// para a menor janela.
+func (m Markdown) String() string {
+	rules := append(Markdown(nil), m...)
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Within > rules[j].Within })
+
+	parts := make([]string, len(rules))
+	for i, r := range rules {
+		parts[i] = fmt.Sprintf("%s:%d", r.Within, r.PercentOff)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// PercentOff devolve o desconto, em porcentagem, de uma fruta que expira em
+// expiration (timestamp Unix) no instante now. A comparação é feita em
+// segundos, pois o tempo restante pode não caber em um time.Duration.
+func (m Markdown) PercentOff(expiration int64, now time.Time) int64 {
+	remaining := expiration - now.Unix()
+
+	var best *MarkdownRule
+	for i, r := range m {
+		if remaining <= int64(r.Within/time.Second) && (best == nil || r.Within < best.Within) {
+			best = &m[i]
+		}
+	}
+
+	if best == nil {
+		return 0
+	}
+
+	return best.PercentOff
+}
+
+// Price devolve o preço efetivo da fruta no instante now. O desconto é
+// arredondado para a unidade menor mais próxima da moeda e calculado em duas
+// partes, para que a multiplicação pelo percentual não estoure o int64.
+func (m Markdown) Price(f Fruit, now time.Time) Money {
+	percent := m.PercentOff(f.ExpirationTime, now)
+	amount := f.Price.Amount
+	discount := amount/100*percent + (amount%100*percent+50)/100
+
+	return Money{Amount: f.Price.Amount - discount, Currency: f.Price.Currency}
+}
+
+// PriceFruits preenche o preço efetivo das frutas no instante now.
+func (m Markdown) PriceFruits(fruits []Fruit, now time.Time) {
+	for i := range fruits {
+		fruits[i].EffectivePrice = m.Price(fruits[i], now)
+	}
+}
+
+// PriceBucket preenche o preço efetivo das frutas do balde e o valor total
+// efetivo no instante now.
+func (m Markdown) PriceBucket(d *BucketDetails, now time.Time) {
+	m.PriceFruits(d.Fruits, now)
+
+	d.EffectiveTotalValue = MoneyTotals{}
+	for _, fruit := range d.Fruits {
+		d.EffectiveTotalValue.Add(fruit.EffectivePrice)
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMarkdownPrice(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	m := Markdown{{Within: 24 * time.Hour, PercentOff: 20}, {Within: 6 * time.Hour, PercentOff: 50}}
+
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		price     Money
+		want      Money
+	}{
+		{"far from expiration", 48 * time.Hour, Money{1000, "BRL"}, Money{1000, "BRL"}},
+		{"exactly 24h", 24 * time.Hour, Money{1000, "BRL"}, Money{800, "BRL"}},
+		{"within 24h", 12 * time.Hour, Money{1000, "BRL"}, Money{800, "BRL"}},
+		{"within 6h", time.Hour, Money{1000, "BRL"}, Money{500, "BRL"}},
+		{"already expired", -time.Minute, Money{1000, "BRL"}, Money{500, "BRL"}},
+		{"rounded discount", time.Hour, Money{99, "USD"}, Money{49, "USD"}},
+		{"no minor unit", 12 * time.Hour, Money{333, "JPY"}, Money{266, "JPY"}},
+	}
+
+	for _, tt := range tests {
+		f := Fruit{Price: tt.price, ExpirationTime: now.Add(tt.expiresIn).Unix()}
+		if got := m.Price(f, now); got != tt.want {
+			t.Errorf("%s: Expected %+v. Got %+v", tt.name, tt.want, got)
+		}
+	}
+
+	// Expirações além de ~292 anos não cabem em um time.Duration.
+	f := Fruit{Price: Money{1000, "BRL"}, ExpirationTime: MaxExpirationTime}
+	if got := m.Price(f, now); got != f.Price {
+		t.Errorf("Expected no discount for a far-off expiration. Got %+v", got)
+	}
+
+	f = Fruit{Price: Money{math.MaxInt64, "BRL"}, ExpirationTime: now.Unix()}
+	if got, want := m.Price(f, now), (Money{math.MaxInt64 / 2, "BRL"}); got != want {
+		t.Errorf("Expected %+v for a huge price. Got %+v", want, got)
+	}
+
+	f = Fruit{Price: Money{1000, "BRL"}, ExpirationTime: now.Unix()}
+	if got := Markdown(nil).Price(f, now); got != f.Price {
+		t.Errorf("Expected the base price without rules. Got %+v", got)
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	m, err := ParseMarkdown("6h:50, 24h:20")
+	if err != nil {
+		t.Fatalf("ParseMarkdown: %v", err)
+	}
+	if len(m) != 2 || m[0] != (MarkdownRule{6 * time.Hour, 50}) || m[1] != (MarkdownRule{24 * time.Hour, 20}) {
+		t.Errorf("Unexpected rules %+v", m)
+	}
+	if m.String() != "24h0m0s:20,6h0m0s:50" {
+		t.Errorf("Unexpected string %q", m.String())
+	}
+
+	if m, err := ParseMarkdown(""); err != nil || m != nil {
+		t.Errorf("Expected no rules. Got %+v (%v)", m, err)
+	}
+
+	for _, input := range []string{"24h", "soon:20", "24h:x", "24h:0", "24h:101", "-1h:10", "6h:10,6h:20"} {
+		if _, err := ParseMarkdown(input); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
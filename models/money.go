@@ -0,0 +1,267 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency é a moeda assumida quando o preço chega sem moeda.
+const DefaultCurrency = "BRL"
+
+// MaxAmount é o maior valor absoluto aceito por ParseMoney, em unidades
+// menores: 10 bilhões de reais, por exemplo. Com ele, os totais só estouram o
+// int64 depois de mais de 9 milhões de quantias no limite.
+const MaxAmount int64 = 1_000_000_000_000
+
+// currencyExponents guarda, para cada moeda aceita (ISO 4217), quantas casas
+// decimais tem a unidade menor.
+var currencyExponents = map[string]int{
+	"ARS": 2,
+	"BRL": 2,
+	"CHF": 2,
+	"CLP": 0,
+	"EUR": 2,
+	"GBP": 2,
+	"JPY": 0,
+	"KWD": 3,
+	"MXN": 2,
+	"USD": 2,
+}
+
+var (
+	// ErrUnknownCurrency indica um código de moeda fora de currencyExponents.
+	ErrUnknownCurrency = errors.New("moeda desconhecida")
+	// ErrInvalidAmount indica uma quantia que não é um decimal válido para a
+	// moeda, como "1,50", "1e3" ou casas decimais demais.
+	ErrInvalidAmount = errors.New("quantia inválida")
+	// ErrCurrencyMismatch indica uma operação entre moedas diferentes.
+	ErrCurrencyMismatch = errors.New("moedas diferentes")
+	// ErrAmountOverflow indica uma soma que não cabe em int64.
+	ErrAmountOverflow = errors.New("a soma das quantias excede o limite")
+)
+
+// Money é um valor monetário exato: a quantia em unidades menores da moeda
+// (centavos, no caso do real) e o código ISO 4217 da moeda.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// Currencies lista os códigos de moeda aceitos, em ordem alfabética.
+func Currencies() []string {
+	codes := make([]string, 0, len(currencyExponents))
+	for code := range currencyExponents {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	return codes
+}
+
+// ValidCurrency indica se o código de moeda é aceito.
+func ValidCurrency(currency string) bool {
+	_, ok := currencyExponents[currency]
+	return ok
+}
+
+// ParseMoney lê uma quantia decimal, como "10.30", na moeda informada. Não
+// aceita mais casas decimais do que a moeda tem nem quantias acima de
+// MaxAmount.
+func ParseMoney(amount, currency string) (Money, error) {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	digits := strings.TrimPrefix(amount, "-")
+	whole, frac, hasPoint := strings.Cut(digits, ".")
+	if whole == "" || (hasPoint && frac == "") || len(frac) > exponent || !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+
+	minor, err := strconv.ParseInt(whole+frac+strings.Repeat("0", exponent-len(frac)), 10, 64)
+	if err != nil || minor > MaxAmount {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if strings.HasPrefix(amount, "-") {
+		minor = -minor
+	}
+
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String formata a quantia como decimal, com todas as casas da moeda.
+func (m Money) String() string {
+	exponent := currencyExponents[m.Currency]
+
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+
+	point := len(digits) - exponent
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// IsPositive indica se a quantia é maior que zero.
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// Add soma duas quantias da mesma moeda.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s e %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+
+	sum, err := addAmounts(m.Amount, other.Amount)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// addAmounts soma duas quantias, devolvendo ErrAmountOverflow se o resultado
+// não couber em int64.
+func addAmounts(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrAmountOverflow
+	}
+
+	return a + b, nil
+}
+
+// moneyJSON é a forma serializada de Money.
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON serializa a quantia como texto decimal, para que nenhum cliente
+// precise passar por ponto flutuante: {"amount": "10.30", "currency": "BRL"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.Currency})
+}
+
+// UnmarshalJSON aceita o mesmo formato de MarshalJSON ou apenas o texto
+// decimal, que fica na moeda padrão. Números JSON são recusados.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &v.Amount); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("%w: use um texto decimal, como \"1.50\"", ErrInvalidAmount)
+	}
+
+	if v.Currency == "" {
+		v.Currency = DefaultCurrency
+	}
+
+	parsed, err := ParseMoney(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// MoneyTotals acumula quantias de várias moedas, com um total por moeda. É
+// serializado como um objeto da moeda para o texto decimal, por exemplo
+// {"BRL": "10.30", "USD": "2.00"}.
+type MoneyTotals map[string]int64
+
+// Add soma a quantia ao total da moeda dela. Se a soma estourar, o total não
+// é alterado e o erro é ErrAmountOverflow; com as quantias limitadas por
+// MaxAmount, isso só acontece com milhões de frutas, então os totais
+// calculados neste pacote ignoram o erro.
+func (t MoneyTotals) Add(m Money) error {
+	sum, err := addAmounts(t[m.Currency], m.Amount)
+	if err != nil {
+		return err
+	}
+
+	t[m.Currency] = sum
+	return nil
+}
+
+// Sub subtrai a quantia do total da moeda dela, com as mesmas regras de Add.
+func (t MoneyTotals) Sub(m Money) error {
+	if m.Amount == math.MinInt64 {
+		return ErrAmountOverflow
+	}
+
+	return t.Add(Money{Amount: -m.Amount, Currency: m.Currency})
+}
+
+// Clone devolve uma cópia independente dos totais.
+func (t MoneyTotals) Clone() MoneyTotals {
+	c := make(MoneyTotals, len(t))
+	for currency, amount := range t {
+		c[currency] = amount
+	}
+
+	return c
+}
+
+// Get devolve o total da moeda, zero se não houver nada nela.
+func (t MoneyTotals) Get(currency string) Money {
+	return Money{Amount: t[currency], Currency: currency}
+}
+
+func (t MoneyTotals) MarshalJSON() ([]byte, error) {
+	out := make(map[string]string, len(t))
+	for currency, amount := range t {
+		out[currency] = Money{Amount: amount, Currency: currency}.String()
+	}
+
+	return json.Marshal(out)
+}
+
+func (t *MoneyTotals) UnmarshalJSON(data []byte) error {
+	var in map[string]string
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	totals := make(MoneyTotals, len(in))
+	for currency, amount := range in {
+		m, err := ParseMoney(amount, currency)
+		if err != nil {
+			return err
+		}
+		if err := totals.Add(m); err != nil {
+			return err
+		}
+	}
+
+	*t = totals
+	return nil
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     Money
+		err      error
+	}{
+		{"10.30", "BRL", Money{1030, "BRL"}, nil},
+		{"10.3", "BRL", Money{1030, "BRL"}, nil},
+		{"10", "BRL", Money{1000, "BRL"}, nil},
+		{"0.05", "USD", Money{5, "USD"}, nil},
+		{"-1.50", "EUR", Money{-150, "EUR"}, nil},
+		{"500", "JPY", Money{500, "JPY"}, nil},
+		{"1.234", "KWD", Money{1234, "KWD"}, nil},
+		{"1.005", "BRL", Money{}, ErrInvalidAmount},
+		{"1.5", "JPY", Money{}, ErrInvalidAmount},
+		{"1,50", "BRL", Money{}, ErrInvalidAmount},
+		{"1e3", "BRL", Money{}, ErrInvalidAmount},
+		{".5", "BRL", Money{}, ErrInvalidAmount},
+		{"1.", "BRL", Money{}, ErrInvalidAmount},
+		{"", "BRL", Money{}, ErrInvalidAmount},
+		{"1.00", "XYZ", Money{}, ErrUnknownCurrency},
+		{"10000000000.00", "BRL", Money{MaxAmount, "BRL"}, nil},
+		{"-10000000000.00", "BRL", Money{-MaxAmount, "BRL"}, nil},
+		{"10000000000.01", "BRL", Money{}, ErrInvalidAmount},
+		{"-10000000000.01", "BRL", Money{}, ErrInvalidAmount},
+		{"92233720368547758.07", "BRL", Money{}, ErrInvalidAmount},
+		{"1000000000001", "JPY", Money{}, ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.amount, tt.currency)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("ParseMoney(%q, %q): Expected %+v, %v. Got %+v, %v", tt.amount, tt.currency, tt.want, tt.err, got, err)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{1030, "BRL"}, "10.30"},
+		{Money{5, "USD"}, "0.05"},
+		{Money{0, "BRL"}, "0.00"},
+		{Money{-150, "EUR"}, "-1.50"},
+		{Money{500, "JPY"}, "500"},
+		{Money{1, "KWD"}, "0.001"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("Expected %q. Got %q", tt.want, got)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	// 0.1 + 0.2 não tem erro de ponto flutuante em centavos
+	sum, err := Money{10, "BRL"}.Add(Money{20, "BRL"})
+	if err != nil || sum.String() != "0.30" {
+		t.Errorf("Expected 0.30. Got %v (%v)", sum, err)
+	}
+
+	if _, err := (Money{10, "BRL"}).Add(Money{10, "USD"}); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected ErrCurrencyMismatch. Got %v", err)
+	}
+
+	if _, err := (Money{math.MaxInt64, "BRL"}).Add(Money{1, "BRL"}); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Expected ErrAmountOverflow. Got %v", err)
+	}
+
+	if _, err := (Money{math.MinInt64, "BRL"}).Add(Money{-1, "BRL"}); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Expected ErrAmountOverflow. Got %v", err)
+	}
+}
+
+func TestMoneyTotalsOverflow(t *testing.T) {
+	totals := MoneyTotals{"BRL": math.MaxInt64 - 10}
+
+	if err := totals.Add(Money{11, "BRL"}); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Expected ErrAmountOverflow. Got %v", err)
+	}
+	if got := totals.Get("BRL").Amount; got != math.MaxInt64-10 {
+		t.Errorf("Expected the total to be unchanged after an overflow. Got %d", got)
+	}
+
+	if err := totals.Add(Money{10, "BRL"}); err != nil || totals.Get("BRL").Amount != math.MaxInt64 {
+		t.Errorf("Expected the total to reach MaxInt64. Got %d (%v)", totals.Get("BRL").Amount, err)
+	}
+
+	totals = MoneyTotals{"BRL": math.MinInt64 + 10}
+	if err := totals.Sub(Money{11, "BRL"}); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Expected ErrAmountOverflow. Got %v", err)
+	}
+	if err := totals.Sub(Money{math.MinInt64, "BRL"}); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("Expected ErrAmountOverflow. Got %v", err)
+	}
+	if got := totals.Get("BRL").Amount; got != math.MinInt64+10 {
+		t.Errorf("Expected the total to be unchanged after an overflow. Got %d", got)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(Money{1030, "USD"})
+	if err != nil || string(data) != `{"amount":"10.30","currency":"USD"}` {
+		t.Errorf("Unexpected JSON %s (%v)", data, err)
+	}
+
+	var m Money
+	if err := json.Unmarshal(data, &m); err != nil || m != (Money{1030, "USD"}) {
+		t.Errorf("Expected round trip. Got %+v (%v)", m, err)
+	}
+
+	if err := json.Unmarshal([]byte(`"2.50"`), &m); err != nil || m != (Money{250, DefaultCurrency}) {
+		t.Errorf("Expected a bare amount in the default currency. Got %+v (%v)", m, err)
+	}
+
+	for _, input := range []string{`2.5`, `"2.505"`, `{"amount":"1","currency":"XYZ"}`} {
+		if err := json.Unmarshal([]byte(input), &m); err == nil {
+			t.Errorf("Expected an error for %s", input)
+		}
+	}
+
+	totals := MoneyTotals{}
+	totals.Add(Money{150, "BRL"})
+	totals.Add(Money{250, "BRL"})
+	totals.Add(Money{100, "JPY"})
+	data, err = json.Marshal(totals)
+	if err != nil || string(data) != `{"BRL":"4.00","JPY":"100"}` {
+		t.Errorf("Unexpected totals JSON %s (%v)", data, err)
+	}
+}
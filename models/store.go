@@ -0,0 +1,136 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound é retornado pelos stores quando o registro solicitado não existe.
+	ErrNotFound = errors.New("registro não encontrado")
+	// ErrBucketNotFound indica que o balde não existe.
+	ErrBucketNotFound = fmt.Errorf("balde: %w", ErrNotFound)
+	// ErrFruitNotFound indica que a fruta não existe.
+	ErrFruitNotFound = fmt.Errorf("fruta: %w", ErrNotFound)
+	// ErrBucketFull indica que o balde já atingiu a sua capacidade.
+	ErrBucketFull = errors.New("capacidade máxima do balde atingida")
+	// ErrWeightLimitExceeded indica que a fruta ultrapassaria o peso máximo
+	// do balde. Também satisfaz errors.Is(err, ErrBucketFull).
+	ErrWeightLimitExceeded = fmt.Errorf("%w: peso máximo excedido", ErrBucketFull)
+	// ErrVolumeLimitExceeded indica que a fruta ultrapassaria o volume máximo
+	// do balde. Também satisfaz errors.Is(err, ErrBucketFull).
+	ErrVolumeLimitExceeded = fmt.Errorf("%w: volume máximo excedido", ErrBucketFull)
+	// ErrFruitTypeNotFound indica que o tipo de fruta não existe.
+	ErrFruitTypeNotFound = fmt.Errorf("tipo de fruta: %w", ErrNotFound)
+	// ErrFruitTypeNameTaken indica que já existe um tipo com o mesmo nome.
+	ErrFruitTypeNameTaken = errors.New("já existe um tipo de fruta com este nome")
+	// ErrFruitTypeInUse indica que ainda há frutas do tipo que se quer excluir.
+	ErrFruitTypeInUse = errors.New("o tipo de fruta ainda tem frutas")
+	// ErrFruitInBucket indica que a fruta já está em algum balde.
+	ErrFruitInBucket = errors.New("a fruta já está em outro balde")
+	// ErrFruitNotInBucket indica que a fruta não está no balde informado.
+	ErrFruitNotInBucket = errors.New("a fruta não está neste balde")
+	// ErrExpirationTooFar indica uma expiração estendida além de
+	// MaxExpirationTime.
+	ErrExpirationTooFar = errors.New("a expiração ultrapassaria o limite permitido")
+	// ErrCapacityBelowCount indica uma capacidade menor que a quantidade de
+	// frutas que o balde já contém.
+	ErrCapacityBelowCount = errors.New("a capacidade não pode ser menor que a quantidade de frutas no balde")
+	// ErrBatchAborted marca as frutas de um lote "tudo ou nada" que não foram
+	// depositadas porque outra fruta do mesmo lote falhou.
+	ErrBatchAborted = errors.New("lote cancelado por falha em outra fruta")
+	// ErrWebhookNotFound indica que o webhook não existe.
+	ErrWebhookNotFound = fmt.Errorf("webhook: %w", ErrNotFound)
+	// ErrDeliveryNotFound indica que a entrega de webhook não existe.
+	ErrDeliveryNotFound = fmt.Errorf("entrega de webhook: %w", ErrNotFound)
+)
+
+// BucketStore define as operações de persistência de baldes.
+type BucketStore interface {
+	CreateBucket(b *Bucket) error
+	GetBucket(id int) (Bucket, error)
+	ListBuckets() ([]Bucket, error)
+	// ListBucketDetails lista os baldes com suas frutas, contagem, valor
+	// total e ocupação, conforme os filtros e a ordenação informados.
+	ListBucketDetails(filter BucketFilter) ([]BucketDetails, error)
+	// UpdateBucket aplica todas as alterações informadas ou nenhuma, e
+	// devolve o balde atualizado. Recusa capacidades menores que a quantidade
+	// de frutas que o balde contém.
+	UpdateBucket(id int, update UpdateBucketRequest) (Bucket, error)
+	// DeleteBucket exclui o balde; as frutas que estavam nele ficam soltas.
+	DeleteBucket(id int) error
+}
+
+// FruitStore define as operações de persistência de frutas.
+type FruitStore interface {
+	CreateFruit(f *Fruit) error
+	// CreateFruits cria o lote em uma única transação, respeitando a
+	// capacidade dos baldes informados nas frutas. Devolve um erro por fruta,
+	// na ordem recebida; se algum não for nil, nenhuma fruta é criada. Em caso
+	// de sucesso, os IDs são preenchidos no próprio slice.
+	CreateFruits(fruits []Fruit) ([]error, error)
+	GetFruit(id int) (Fruit, error)
+	ListFruits(filter FruitFilter) ([]Fruit, error)
+	// UpdateFruit aplica as alterações informadas e devolve a fruta atualizada.
+	UpdateFruit(id int, update UpdateFruitRequest) (Fruit, error)
+	GetFruitsInBucket(bucketID int) ([]Fruit, error)
+	// AddFruitToBucket deposita uma fruta solta em um balde, respeitando a
+	// capacidade e os limites de peso e volume dele (ver Bucket.CheckFit).
+	AddFruitToBucket(fruitID, bucketID int) error
+	// AddFruitsToBucket deposita várias frutas no balde em uma única
+	// transação, devolvendo um erro por fruta, na ordem recebida (nil quando
+	// a fruta foi depositada). Com allOrNothing, qualquer falha desfaz o lote
+	// inteiro e as demais frutas recebem ErrBatchAborted.
+	AddFruitsToBucket(bucketID int, fruitIDs []int, allOrNothing bool) ([]error, error)
+	RemoveFruitFromBucket(fruitID, bucketID int) (int64, error)
+	// MoveFruit transfere atomicamente uma fruta de um balde para outro, com
+	// as mesmas verificações de AddFruitToBucket para o balde de destino.
+	MoveFruit(fruitID, fromBucketID, toBucketID int) error
+	// ApplyMoves aplica os movimentos em ordem, em uma única transação, com as
+	// verificações de MoveFruit. Se algum falhar, nada é alterado e o erro
+	// informa a posição do movimento.
+	ApplyMoves(moves []FruitMove) error
+	DeleteFruit(id int) error
+	// ExpireFruits move para o arquivo as frutas vencidas até `now`,
+	// devolvendo as frutas arquivadas.
+	ExpireFruits(now int64) ([]ExpiredFruit, error)
+	// ListExpiredFruits lista as frutas arquivadas cuja expiração está no
+	// intervalo [from, to]; zero em qualquer extremo significa sem limite.
+	ListExpiredFruits(from, to int64) ([]ExpiredFruit, error)
+}
+
+// FruitTypeStore define as operações de persistência do catálogo de tipos de
+// fruta. Os nomes são únicos, sem diferenciar maiúsculas de minúsculas.
+type FruitTypeStore interface {
+	CreateFruitType(t *FruitType) error
+	GetFruitType(id int) (FruitType, error)
+	// ListFruitTypes lista os tipos em ordem de ID.
+	ListFruitTypes() ([]FruitType, error)
+	// UpdateFruitType aplica as alterações informadas e devolve o tipo
+	// atualizado. As frutas já criadas não mudam.
+	UpdateFruitType(id int, update UpdateFruitTypeRequest) (FruitType, error)
+	// DeleteFruitType exclui o tipo, recusando com ErrFruitTypeInUse enquanto
+	// houver frutas dele.
+	DeleteFruitType(id int) error
+}
+
+// WebhookStore define as operações de persistência dos webhooks e do
+// registro das entregas.
+type WebhookStore interface {
+	CreateWebhook(w *Webhook) error
+	GetWebhook(id int) (Webhook, error)
+	// ListWebhooks lista os webhooks em ordem de ID.
+	ListWebhooks() ([]Webhook, error)
+	// DeleteWebhook exclui o webhook junto com o registro das entregas dele.
+	DeleteWebhook(id int) error
+	CreateDelivery(d *WebhookDelivery) error
+	// UpdateDelivery grava a situação, as tentativas e o resultado da última
+	// tentativa da entrega.
+	UpdateDelivery(d WebhookDelivery) error
+	// ListDeliveries lista as entregas do webhook, da mais recente para a
+	// mais antiga.
+	ListDeliveries(webhookID int) ([]WebhookDelivery, error)
+	// ListPendingDeliveries lista as entregas ainda pendentes de todos os
+	// webhooks, em ordem de ID.
+	ListPendingDeliveries() ([]WebhookDelivery, error)
+}
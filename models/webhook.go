@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+// Eventos que podem ser assinados por um webhook.
+const (
+	EventFruitCreated   = "fruit.created"
+	EventFruitDeposited = "fruit.deposited"
+	EventFruitRemoved   = "fruit.removed"
+	EventFruitExpired   = "fruit.expired"
+	EventBucketFull     = "bucket.full"
+	EventBucketDeleted  = "bucket.deleted"
+)
+
+// WebhookEvents lista os eventos aceitos, na ordem da documentação.
+var WebhookEvents = []string{
+	EventFruitCreated,
+	EventFruitDeposited,
+	EventFruitRemoved,
+	EventFruitExpired,
+	EventBucketFull,
+	EventBucketDeleted,
+}
+
+// ValidWebhookEvent indica se o evento pode ser assinado.
+func ValidWebhookEvent(event string) bool {
+	return slices.Contains(WebhookEvents, event)
+}
+
+// Webhook é a assinatura de uma URL que recebe os eventos em Events. Secret
+// assina o corpo de cada entrega (ver o pacote webhook) e só é devolvido na
+// criação.
+type Webhook struct {
+	ID        int      `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+// Subscribed indica se o webhook assina o evento.
+func (w Webhook) Subscribed(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+// Situações de uma entrega de webhook.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery é o registro de uma entrega de evento a um webhook. Payload
+// é o corpo enviado, idêntico em todas as tentativas; os campos Last* trazem
+// o resultado da última tentativa.
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	CreatedAt      int64           `json:"created_at"`
+	UpdatedAt      int64           `json:"updated_at"`
+}
+
+// FruitBucketEvent é o conteúdo dos eventos de depósito e remoção de frutas.
+type FruitBucketEvent struct {
+	BucketID int   `json:"bucket_id"`
+	Fruit    Fruit `json:"fruit"`
+}
@@ -0,0 +1,160 @@
+// Package placement escolhe automaticamente o balde que recebe uma fruta,
+// segundo uma estratégia plugável.
+package placement
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// ErrNoBucketAvailable indica que nenhum balde com espaço serve para a fruta.
+var ErrNoBucketAvailable = errors.New("nenhum balde com espaço disponível")
+
+// maxAttempts limita as novas tentativas quando o balde escolhido enche
+// entre a escolha e o depósito.
+const maxAttempts = 3
+
+// Strategy escolhe o balde que deve receber uma fruta.
+type Strategy interface {
+	// Choose devolve o ID do balde escolhido entre os candidatos, todos com
+	// espaço livre para a fruta (inclusive de peso e volume) e ordenados por
+	// ID, ou false se nenhum servir.
+	Choose(fruit models.Fruit, candidates []models.BucketDetails) (int, bool)
+}
+
+// StrategyFunc adapta uma função comum para a interface Strategy.
+type StrategyFunc func(fruit models.Fruit, candidates []models.BucketDetails) (int, bool)
+
+func (f StrategyFunc) Choose(fruit models.Fruit, candidates []models.BucketDetails) (int, bool) {
+	return f(fruit, candidates)
+}
+
+// Nomes das estratégias disponíveis.
+const (
+	NameFirstFit     = "first_fit"
+	NameBestFit      = "best_fit"
+	NameWorstFit     = "worst_fit"
+	NameBalanceValue = "balance_value"
+)
+
+var (
+	// FirstFit escolhe o balde de menor ID com espaço.
+	FirstFit Strategy = StrategyFunc(firstFit)
+	// BestFit escolhe o balde mais cheio que ainda tem espaço.
+	BestFit Strategy = minBy(func(_ models.Fruit, d models.BucketDetails) float64 { return -d.Occupancy })
+	// WorstFit escolhe o balde mais vazio.
+	WorstFit Strategy = minBy(func(_ models.Fruit, d models.BucketDetails) float64 { return d.Occupancy })
+	// BalanceValue escolhe o balde de menor valor total na moeda da fruta,
+	// equilibrando o valor guardado em cada um.
+	BalanceValue Strategy = minBy(func(f models.Fruit, d models.BucketDetails) float64 {
+		return float64(d.TotalValue[f.Price.Currency])
+	})
+)
+
+// strategies registra as estratégias pelo nome.
+var strategies = map[string]Strategy{
+	NameFirstFit:     FirstFit,
+	NameBestFit:      BestFit,
+	NameWorstFit:     WorstFit,
+	NameBalanceValue: BalanceValue,
+}
+
+// Names lista os nomes das estratégias registradas, em ordem alfabética.
+func Names() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Lookup devolve a estratégia registrada com o nome informado.
+func Lookup(name string) (Strategy, error) {
+	strategy, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("estratégia de alocação desconhecida %q (use %s)", name, strings.Join(Names(), ", "))
+	}
+
+	return strategy, nil
+}
+
+func firstFit(_ models.Fruit, candidates []models.BucketDetails) (int, bool) {
+	if len(candidates) == 0 {
+		return 0, false
+	}
+
+	return candidates[0].ID, true
+}
+
+// minBy monta uma estratégia que escolhe o candidato de menor chave; os
+// empates ficam com o menor ID, já que os candidatos chegam ordenados.
+func minBy(key func(models.Fruit, models.BucketDetails) float64) Strategy {
+	return StrategyFunc(func(fruit models.Fruit, candidates []models.BucketDetails) (int, bool) {
+		if len(candidates) == 0 {
+			return 0, false
+		}
+
+		best := candidates[0]
+		for _, d := range candidates[1:] {
+			if key(fruit, d) < key(fruit, best) {
+				best = d
+			}
+		}
+
+		return best.ID, true
+	})
+}
+
+// Place deposita uma fruta solta no balde escolhido pela estratégia e devolve
+// o ID desse balde. Se o balde encher entre a escolha e o depósito, ele é
+// descartado e a escolha é refeita.
+func Place(buckets models.BucketStore, fruits models.FruitStore, fruitID int, strategy Strategy) (int, error) {
+	fruit, err := fruits.GetFruit(fruitID)
+	if err != nil {
+		return 0, err
+	}
+
+	if fruit.BucketID.Valid {
+		return 0, models.ErrFruitInBucket
+	}
+
+	notFull := false
+	excluded := make(map[int]bool)
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		details, err := buckets.ListBucketDetails(models.BucketFilter{SortBy: models.BucketSortID, Full: &notFull})
+		if err != nil {
+			return 0, err
+		}
+
+		candidates := details[:0]
+		for _, d := range details {
+			if !excluded[d.ID] && d.Bucket().CheckFit(d.Load(), fruit) == nil {
+				candidates = append(candidates, d)
+			}
+		}
+
+		bucketID, ok := strategy.Choose(fruit, candidates)
+		if !ok {
+			return 0, ErrNoBucketAvailable
+		}
+
+		err = fruits.AddFruitToBucket(fruitID, bucketID)
+		switch {
+		case err == nil:
+			return bucketID, nil
+		case errors.Is(err, models.ErrBucketFull), errors.Is(err, models.ErrBucketNotFound):
+			excluded[bucketID] = true
+		default:
+			return 0, err
+		}
+	}
+
+	return 0, ErrNoBucketAvailable
+}
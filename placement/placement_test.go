@@ -0,0 +1,151 @@
+package placement
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mr-utzig/planne-test/database/memory"
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// bucketSpec descreve um balde de teste: a capacidade e o preço, em
+// centavos de real, de cada fruta que ele contém.
+type bucketSpec struct {
+	capacity int
+	prices   []int64
+}
+
+// seed cria um balde por item de specs e devolve os IDs dos baldes.
+func seed(t *testing.T, s *memory.Store, specs []bucketSpec) []int {
+	t.Helper()
+
+	ids := make([]int, len(specs))
+	for i, spec := range specs {
+		b := models.Bucket{Capacity: spec.capacity}
+		if err := s.CreateBucket(&b); err != nil {
+			t.Fatalf("CreateBucket: %v", err)
+		}
+		ids[i] = b.ID
+
+		for _, price := range spec.prices {
+			f := models.Fruit{Name: "Apple", Price: models.Money{Amount: price, Currency: "BRL"}, ExpirationTime: time.Now().Add(time.Hour).Unix()}
+			if err := s.CreateFruit(&f); err != nil {
+				t.Fatalf("CreateFruit: %v", err)
+			}
+			if err := s.AddFruitToBucket(f.ID, b.ID); err != nil {
+				t.Fatalf("AddFruitToBucket: %v", err)
+			}
+		}
+	}
+
+	return ids
+}
+
+func TestStrategies(t *testing.T) {
+	s := memory.New()
+	// Ocupações: 50%, 100% (cheio), 25%, 75%. Valores: 10, -, 1, 3.
+	ids := seed(t, s, []bucketSpec{
+		{2, []int64{1000}},
+		{1, []int64{100}},
+		{4, []int64{100}},
+		{4, []int64{100, 100, 100}},
+	})
+
+	tests := []struct {
+		name string
+		want int
+	}{
+		{NameFirstFit, ids[0]},
+		{NameBestFit, ids[3]},
+		{NameWorstFit, ids[2]},
+		{NameBalanceValue, ids[2]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := Lookup(tt.name)
+			if err != nil {
+				t.Fatalf("Lookup: %v", err)
+			}
+
+			notFull := false
+			candidates, _ := s.ListBucketDetails(models.BucketFilter{SortBy: models.BucketSortID, Full: &notFull})
+
+			got, ok := strategy.Choose(models.Fruit{Price: models.Money{Currency: "BRL"}}, candidates)
+			if !ok || got != tt.want {
+				t.Errorf("Expected bucket %d. Got %d (%v)", tt.want, got, ok)
+			}
+		})
+	}
+
+	if _, err := Lookup("random"); err == nil {
+		t.Errorf("Expected an error for an unknown strategy")
+	}
+}
+
+func TestPlace(t *testing.T) {
+	s := memory.New()
+	ids := seed(t, s, []bucketSpec{
+		{1, nil},
+	})
+
+	newFruit := func() int {
+		f := models.Fruit{Name: "Apple", Price: models.Money{Amount: 100, Currency: "BRL"}, ExpirationTime: time.Now().Add(time.Hour).Unix()}
+		if err := s.CreateFruit(&f); err != nil {
+			t.Fatalf("CreateFruit: %v", err)
+		}
+		return f.ID
+	}
+
+	first := newFruit()
+	got, err := Place(s, s, first, BestFit)
+	if err != nil || got != ids[0] {
+		t.Fatalf("Expected fruit placed in bucket %d. Got %d (%v)", ids[0], got, err)
+	}
+
+	if _, err := Place(s, s, first, BestFit); !errors.Is(err, models.ErrFruitInBucket) {
+		t.Errorf("Expected ErrFruitInBucket. Got %v", err)
+	}
+
+	if _, err := Place(s, s, newFruit(), BestFit); !errors.Is(err, ErrNoBucketAvailable) {
+		t.Errorf("Expected ErrNoBucketAvailable. Got %v", err)
+	}
+
+	if _, err := Place(s, s, first+100, BestFit); !errors.Is(err, models.ErrFruitNotFound) {
+		t.Errorf("Expected ErrFruitNotFound. Got %v", err)
+	}
+}
+
+// fullOnFirstTry simula um balde que enche entre a escolha e o depósito.
+type fullOnFirstTry struct {
+	*memory.Store
+	failed bool
+}
+
+func (s *fullOnFirstTry) AddFruitToBucket(fruitID, bucketID int) error {
+	if !s.failed {
+		s.failed = true
+		return models.ErrBucketFull
+	}
+
+	return s.Store.AddFruitToBucket(fruitID, bucketID)
+}
+
+func TestPlaceRetriesWhenBucketFills(t *testing.T) {
+	s := &fullOnFirstTry{Store: memory.New()}
+	ids := seed(t, s.Store, []bucketSpec{
+		{1, nil},
+		{1, nil},
+	})
+
+	f := models.Fruit{Name: "Apple", Price: models.Money{Amount: 100, Currency: "BRL"}, ExpirationTime: time.Now().Add(time.Hour).Unix()}
+	if err := s.CreateFruit(&f); err != nil {
+		t.Fatalf("CreateFruit: %v", err)
+	}
+
+	got, err := Place(s, s, f.ID, FirstFit)
+	if err != nil || got != ids[1] {
+		t.Errorf("Expected fallback to bucket %d. Got %d (%v)", ids[1], got, err)
+	}
+}
@@ -0,0 +1,271 @@
+// Package rebalance calcula planos de transferência de frutas entre baldes
+// para equilibrar a ocupação ou liberar baldes.
+package rebalance
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// Objetivos de rebalanceamento.
+const (
+	// TargetMinVariance aproxima a ocupação de todos os baldes.
+	TargetMinVariance = "min_variance"
+	// TargetConsolidate esvazia o maior número possível de baldes.
+	TargetConsolidate = "consolidate"
+)
+
+// Stats resume a distribuição das frutas entre os baldes.
+type Stats struct {
+	// OccupancyVariance é a variância populacional das ocupações, em pontos
+	// percentuais ao quadrado.
+	OccupancyVariance float64 `json:"occupancy_variance"`
+	EmptyBuckets      int     `json:"empty_buckets"`
+}
+
+// Plan é o resultado do planejamento: os movimentos, na ordem em que devem
+// ser aplicados, e a distribuição antes e depois deles.
+type Plan struct {
+	Target string             `json:"target"`
+	Moves  []models.FruitMove `json:"moves"`
+	Before Stats              `json:"before"`
+	After  Stats              `json:"after"`
+}
+
+// bucket é o estado de um balde durante o planejamento.
+type bucket struct {
+	id       int
+	capacity int
+	limits   models.Bucket
+	load     models.BucketLoad
+	fruits   []models.Fruit
+	// received indica que o balde já recebeu frutas; ele deixa de ser
+	// candidato a ser esvaziado na consolidação.
+	received bool
+	// emptied indica que o balde está vazio na consolidação, por ter sido
+	// esvaziado ou por já começar assim, e não deve receber frutas.
+	emptied bool
+}
+
+func (b *bucket) occupancy() float64 {
+	return models.OccupancyPercentage(len(b.fruits), b.capacity)
+}
+
+func (b *bucket) hasRoom() bool {
+	return len(b.fruits) < b.capacity
+}
+
+// fits indica se a fruta cabe no balde, considerando também peso e volume.
+func (b *bucket) fits(fruit models.Fruit) bool {
+	return b.limits.CheckFit(b.load, fruit) == nil
+}
+
+// movable devolve o índice da última fruta de b que cabe em dst, ou -1.
+func (b *bucket) movable(dst *bucket) int {
+	for i := len(b.fruits) - 1; i >= 0; i-- {
+		if dst.fits(b.fruits[i]) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// NewPlan calcula o plano para o objetivo informado. Cada movimento respeita
+// a capacidade, o peso e o volume máximos do destino no momento em que é
+// aplicado, então o plano pode ser executado em ordem, movimento a movimento.
+func NewPlan(details []models.BucketDetails, target string) (Plan, error) {
+	buckets := make([]*bucket, len(details))
+	for i, d := range details {
+		buckets[i] = &bucket{
+			id:       d.ID,
+			capacity: d.Capacity,
+			limits:   d.Bucket(),
+			load:     d.Load(),
+			fruits:   append([]models.Fruit(nil), d.Fruits...),
+		}
+	}
+
+	// Ordem estável por ID, para que o mesmo estado gere sempre o mesmo plano.
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].id < buckets[j].id })
+
+	plan := Plan{Target: target, Before: stats(buckets)}
+
+	switch target {
+	case TargetMinVariance:
+		plan.Moves = minVariance(buckets)
+	case TargetConsolidate:
+		plan.Moves = consolidate(buckets)
+	default:
+		return Plan{}, fmt.Errorf("objetivo de rebalanceamento desconhecido %q", target)
+	}
+
+	if plan.Moves == nil {
+		plan.Moves = []models.FruitMove{}
+	}
+	plan.After = stats(buckets)
+
+	return plan, nil
+}
+
+// move transfere a fruta de índice i de from para to e devolve o movimento.
+func move(from, to *bucket, i int) models.FruitMove {
+	fruit := from.fruits[i]
+	from.fruits = append(from.fruits[:i], from.fruits[i+1:]...)
+	from.load.Remove(fruit)
+	to.fruits = append(to.fruits, fruit)
+	to.load.Add(fruit)
+	to.received = true
+
+	return models.FruitMove{FruitID: fruit.ID, FromBucketID: from.id, ToBucketID: to.id}
+}
+
+// minVariance move frutas do balde mais ocupado para o menos ocupado enquanto
+// isso reduzir a soma dos quadrados das ocupações (e, com ela, a variância).
+// É uma heurística gulosa: cada passo melhora a distribuição, mas o resultado
+// não é necessariamente o ótimo quando as capacidades diferem. Se nenhuma
+// fruta do balde mais ocupado couber (por peso ou volume) no menos ocupado, o
+// planejamento para ali.
+func minVariance(buckets []*bucket) []models.FruitMove {
+	var moves []models.FruitMove
+
+	for {
+		var src, dst *bucket
+		for _, b := range buckets {
+			if len(b.fruits) > 0 && (src == nil || b.occupancy() > src.occupancy()) {
+				src = b
+			}
+			if b.hasRoom() && (dst == nil || b.occupancy() < dst.occupancy()) {
+				dst = b
+			}
+		}
+
+		if src == nil || dst == nil || src == dst {
+			return moves
+		}
+
+		srcAfter := models.OccupancyPercentage(len(src.fruits)-1, src.capacity)
+		dstAfter := models.OccupancyPercentage(len(dst.fruits)+1, dst.capacity)
+		delta := srcAfter*srcAfter + dstAfter*dstAfter - src.occupancy()*src.occupancy() - dst.occupancy()*dst.occupancy()
+
+		// A tolerância evita trocas que só oscilam por erro de arredondamento.
+		if delta >= -1e-9 {
+			return moves
+		}
+
+		i := src.movable(dst)
+		if i < 0 {
+			return moves
+		}
+
+		moves = append(moves, move(src, dst, i))
+	}
+}
+
+// consolidate tenta esvaziar os baldes com menos frutas, distribuindo-as nos
+// baldes mais cheios que ainda têm espaço. Um balde só é esvaziado se todas
+// as suas frutas couberem nos demais; a distribuição é simulada antes e
+// descartada se alguma fruta ficar sem destino. Os baldes que já começam
+// vazios não recebem frutas, pois enchê-los não liberaria nenhum balde.
+func consolidate(buckets []*bucket) []models.FruitMove {
+	sources := make([]*bucket, 0, len(buckets))
+	for _, b := range buckets {
+		if len(b.fruits) > 0 {
+			sources = append(sources, b)
+		} else {
+			b.emptied = true
+		}
+	}
+	sort.SliceStable(sources, func(i, j int) bool { return len(sources[i].fruits) < len(sources[j].fruits) })
+
+	var moves []models.FruitMove
+
+	for _, src := range sources {
+		if src.received {
+			continue
+		}
+
+		// Trabalha sobre cópias para poder desistir sem desfazer nada.
+		trial := make([]*bucket, len(buckets))
+		from := 0
+		for i, b := range buckets {
+			c := *b
+			c.fruits = append([]models.Fruit(nil), b.fruits...)
+			trial[i] = &c
+			if b == src {
+				from = i
+			}
+		}
+
+		drained := drain(trial[from], trial)
+		if drained == nil {
+			continue
+		}
+
+		for i, b := range trial {
+			*buckets[i] = *b
+		}
+		src.emptied = true
+		moves = append(moves, drained...)
+	}
+
+	return moves
+}
+
+// drain move todas as frutas de src para os baldes mais cheios que ainda as
+// comportam. Devolve nil se alguma fruta não couber em nenhum balde.
+func drain(src *bucket, buckets []*bucket) []models.FruitMove {
+	var moves []models.FruitMove
+
+	for len(src.fruits) > 0 {
+		last := len(src.fruits) - 1
+
+		var dst *bucket
+		for _, b := range buckets {
+			if b == src || b.emptied || !b.fits(src.fruits[last]) {
+				continue
+			}
+			if dst == nil || b.occupancy() > dst.occupancy() {
+				dst = b
+			}
+		}
+
+		if dst == nil {
+			return nil
+		}
+
+		moves = append(moves, move(src, dst, last))
+	}
+
+	return moves
+}
+
+func stats(buckets []*bucket) Stats {
+	var s Stats
+	if len(buckets) == 0 {
+		return s
+	}
+
+	mean := 0.0
+	for _, b := range buckets {
+		mean += b.occupancy()
+		if len(b.fruits) == 0 {
+			s.EmptyBuckets++
+		}
+	}
+	mean /= float64(len(buckets))
+
+	for _, b := range buckets {
+		diff := b.occupancy() - mean
+		s.OccupancyVariance += diff * diff
+	}
+	s.OccupancyVariance /= float64(len(buckets))
+
+	// Arredonda para não expor resíduos de ponto flutuante na resposta.
+	s.OccupancyVariance = math.Round(s.OccupancyVariance*1e6) / 1e6
+
+	return s
+}
@@ -0,0 +1,199 @@
+package rebalance
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// buckets monta os detalhes de baldes de teste a partir de pares
+// {capacidade, frutas}, com IDs sequenciais para baldes e frutas.
+func buckets(specs [][2]int) []models.BucketDetails {
+	details := make([]models.BucketDetails, len(specs))
+	fruitID := 1
+	for i, spec := range specs {
+		fruits := make([]models.Fruit, spec[1])
+		for j := range fruits {
+			fruits[j] = models.Fruit{ID: fruitID, Price: models.Money{Amount: 100, Currency: "BRL"}, BucketID: sql.NullInt64{Int64: int64(i + 1), Valid: true}}
+			fruitID++
+		}
+		details[i] = models.NewBucketDetails(models.Bucket{ID: i + 1, Capacity: spec[0]}, fruits)
+	}
+
+	return details
+}
+
+// apply simula os movimentos sobre os detalhes, verificando a capacidade do
+// destino e a origem de cada fruta, e devolve a quantidade de frutas por balde.
+func apply(t *testing.T, details []models.BucketDetails, moves []models.FruitMove) map[int]int {
+	t.Helper()
+
+	capacity := make(map[int]int)
+	count := make(map[int]int)
+	location := make(map[int]int)
+	for _, d := range details {
+		capacity[d.ID] = d.Capacity
+		count[d.ID] = len(d.Fruits)
+		for _, f := range d.Fruits {
+			location[f.ID] = d.ID
+		}
+	}
+
+	for i, m := range moves {
+		if location[m.FruitID] != m.FromBucketID {
+			t.Fatalf("Move %d: fruit %d is not in bucket %d", i, m.FruitID, m.FromBucketID)
+		}
+		if count[m.ToBucketID] >= capacity[m.ToBucketID] {
+			t.Fatalf("Move %d: bucket %d is full", i, m.ToBucketID)
+		}
+
+		location[m.FruitID] = m.ToBucketID
+		count[m.FromBucketID]--
+		count[m.ToBucketID]++
+	}
+
+	return count
+}
+
+func TestMinVariance(t *testing.T) {
+	details := buckets([][2]int{{10, 10}, {10, 1}, {10, 4}})
+
+	plan, err := NewPlan(details, TargetMinVariance)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+
+	count := apply(t, details, plan.Moves)
+	for id, n := range count {
+		if n != 5 {
+			t.Errorf("Expected 5 fruits in bucket %d. Got %d", id, n)
+		}
+	}
+
+	if plan.After.OccupancyVariance != 0 || plan.Before.OccupancyVariance <= 0 {
+		t.Errorf("Expected variance to drop to zero. Got %+v -> %+v", plan.Before, plan.After)
+	}
+}
+
+func TestMinVarianceWithDifferentCapacities(t *testing.T) {
+	details := buckets([][2]int{{4, 4}, {8, 0}})
+
+	plan, err := NewPlan(details, TargetMinVariance)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+
+	// Com 1/4 e 3/8 as ocupações ficam em 25% e 37,5%; mover mais uma fruta
+	// em qualquer direção aumentaria a variância.
+	count := apply(t, details, plan.Moves)
+	if count[1] != 1 || count[2] != 3 {
+		t.Errorf("Expected 1 and 3 fruits. Got %v", count)
+	}
+	if plan.After.OccupancyVariance >= plan.Before.OccupancyVariance {
+		t.Errorf("Expected variance to drop. Got %+v -> %+v", plan.Before, plan.After)
+	}
+}
+
+func TestConsolidate(t *testing.T) {
+	details := buckets([][2]int{{5, 4}, {5, 1}, {5, 2}, {3, 0}})
+
+	plan, err := NewPlan(details, TargetConsolidate)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+
+	// A fruta do balde 2 vai para o 1, o mais cheio com vaga; depois disso
+	// só o balde 4, que começou vazio, teria espaço para as duas frutas do 3,
+	// e movê-las para lá não liberaria nenhum balde.
+	count := apply(t, details, plan.Moves)
+	if len(plan.Moves) != 1 {
+		t.Errorf("Expected 1 move. Got %+v", plan.Moves)
+	}
+	want := map[int]int{1: 5, 2: 0, 3: 2, 4: 0}
+	for id, n := range want {
+		if count[id] != n {
+			t.Errorf("Expected %d fruits in bucket %d. Got %d", n, id, count[id])
+		}
+	}
+	if plan.After.EmptyBuckets <= plan.Before.EmptyBuckets {
+		t.Errorf("Expected more empty buckets. Got %+v -> %+v", plan.Before, plan.After)
+	}
+}
+
+func TestConsolidateSkipsEmptyBuckets(t *testing.T) {
+	details := buckets([][2]int{{5, 1}, {5, 0}, {5, 2}})
+
+	plan, err := NewPlan(details, TargetConsolidate)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+
+	// A fruta do balde 1 vai para o 3; o balde 2, vazio desde o início,
+	// não recebe nada.
+	count := apply(t, details, plan.Moves)
+	want := map[int]int{1: 0, 2: 0, 3: 3}
+	for id, n := range want {
+		if count[id] != n {
+			t.Errorf("Expected %d fruits in bucket %d. Got %d", n, id, count[id])
+		}
+	}
+	if plan.Before.EmptyBuckets != 1 || plan.After.EmptyBuckets != 2 {
+		t.Errorf("Expected one more empty bucket. Got %+v -> %+v", plan.Before, plan.After)
+	}
+
+	// Sem outro balde com frutas, não há o que consolidar.
+	details = buckets([][2]int{{5, 1}, {5, 0}})
+	if plan, _ := NewPlan(details, TargetConsolidate); len(plan.Moves) != 0 {
+		t.Errorf("Expected no moves into an empty bucket. Got %+v", plan.Moves)
+	}
+}
+
+func TestMinVarianceKeepsBalancedBuckets(t *testing.T) {
+	plan, err := NewPlan(buckets([][2]int{{4, 2}, {4, 2}}), TargetMinVariance)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+
+	if len(plan.Moves) != 0 {
+		t.Errorf("Expected no moves. Got %+v", plan.Moves)
+	}
+}
+
+func TestUnknownTarget(t *testing.T) {
+	if _, err := NewPlan(nil, "shuffle"); err == nil {
+		t.Errorf("Expected an error for an unknown target")
+	}
+}
+
+func TestPlansRespectWeightLimit(t *testing.T) {
+	details := buckets([][2]int{{10, 4}, {10, 0}, {10, 0}})
+	limit := 1.0
+	details[1] = models.NewBucketDetails(models.Bucket{ID: 2, Capacity: 10, MaxWeight: &limit}, nil)
+	for i := range details[0].Fruits {
+		weight := 1.0
+		details[0].Fruits[i].Weight = &weight
+	}
+	details[0].CalcDimensions()
+
+	plan, err := NewPlan(details, TargetMinVariance)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+
+	// O balde 2 só comporta 1kg, então recebe uma fruta e o 3 recebe uma.
+	count := apply(t, details, plan.Moves)
+	if count[1] != 2 || count[2] != 1 || count[3] != 1 {
+		t.Errorf("Expected 2, 1 and 1 fruits. Got %v", count)
+	}
+
+	plan, err = NewPlan(details[:2], TargetConsolidate)
+	if err != nil {
+		t.Fatalf("NewPlan: %v", err)
+	}
+
+	// Sem o balde 3, as 4 frutas não cabem no balde 2: nada a mover.
+	if len(plan.Moves) != 0 {
+		t.Errorf("Expected no moves. Got %+v", plan.Moves)
+	}
+}
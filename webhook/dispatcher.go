@@ -0,0 +1,336 @@
+// Package webhook entrega os eventos da API aos webhooks cadastrados. Cada
+// entrega é um POST com corpo JSON assinado por HMAC-SHA256, repetido com
+// backoff exponencial até o receptor responder 2xx, e fica registrada no
+// store junto com o resultado da última tentativa.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// Cabeçalhos enviados em cada entrega.
+const (
+	// SignatureHeader traz "sha256=" seguido do HMAC-SHA256 do corpo, em
+	// hexadecimal, calculado com o segredo do webhook (ver Sign).
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	// DeliveryHeader traz o ID da entrega, o mesmo em todas as tentativas,
+	// para que o receptor descarte repetições.
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// Valores padrão das novas tentativas.
+const (
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = 5 * time.Minute
+)
+
+// QueueSize é a quantidade de eventos que aguardam registro; com a fila
+// cheia, Publish descarta o evento em vez de bloquear.
+const QueueSize = 1024
+
+// Envelope é o corpo JSON de cada entrega.
+type Envelope struct {
+	Event      string `json:"event"`
+	OccurredAt int64  `json:"occurred_at"`
+	Data       any    `json:"data"`
+}
+
+// Dispatcher registra e envia as entregas em background: uma goroutine
+// consome a fila de eventos publicados e registra as entregas, e cada entrega
+// é enviada em uma goroutine própria. As entregas interrompidas por Close
+// continuam pendentes no store e são retomadas por Load na próxima execução.
+type Dispatcher struct {
+	store  models.WebhookStore
+	client *http.Client
+
+	// MaxAttempts é o total de tentativas antes de a entrega falhar. A
+	// espera antes da tentativa n+1 é Backoff * 2^(n-1), limitada a
+	// MaxBackoff.
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+
+	queue  chan queuedEvent
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// queuedEvent é um evento publicado que aguarda o registro das entregas.
+type queuedEvent struct {
+	event      string
+	data       any
+	occurredAt int64
+}
+
+// NewDispatcher cria um dispatcher sobre o store de webhooks, com os valores
+// padrão de tentativas; eles podem ser alterados antes do primeiro evento.
+func NewDispatcher(store models.WebhookStore) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		queue:       make(chan queuedEvent, QueueSize),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for e := range d.queue {
+			d.record(e)
+		}
+	}()
+
+	return d
+}
+
+// Load retoma as entregas que ficaram pendentes no store.
+func (d *Dispatcher) Load() error {
+	pending, err := d.store.ListPendingDeliveries()
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range pending {
+		d.start(delivery)
+	}
+
+	return nil
+}
+
+// Publish põe o evento na fila e retorna sem acessar o store; as entregas
+// são registradas e enviadas em background. Com a fila cheia ou o
+// dispatcher fechado, o evento é descartado e apenas logado, para não
+// afetar a operação que o gerou. Data não deve ser alterado depois da
+// publicação.
+func (d *Dispatcher) Publish(event string, data any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		log.Println("Evento de webhook descartado, o dispatcher foi fechado:", event)
+		return
+	}
+
+	select {
+	case d.queue <- queuedEvent{event: event, data: data, occurredAt: time.Now().Unix()}:
+	default:
+		log.Println("Evento de webhook descartado, a fila está cheia:", event)
+	}
+}
+
+// record registra uma entrega do evento para cada webhook que o assina e as
+// envia. Falhas ao registrar são apenas logadas.
+func (d *Dispatcher) record(e queuedEvent) {
+	webhooks, err := d.store.ListWebhooks()
+	if err != nil {
+		log.Println("Erro ao buscar webhooks:", err)
+		return
+	}
+
+	var payload []byte
+	for _, w := range webhooks {
+		if !w.Subscribed(e.event) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(Envelope{Event: e.event, OccurredAt: e.occurredAt, Data: e.data}); err != nil {
+				log.Println("Erro ao montar o evento de webhook:", err)
+				return
+			}
+		}
+
+		delivery := models.WebhookDelivery{
+			WebhookID: w.ID,
+			Event:     e.event,
+			Payload:   payload,
+			Status:    models.DeliveryPending,
+			CreatedAt: e.occurredAt,
+			UpdatedAt: e.occurredAt,
+		}
+		if err := d.store.CreateDelivery(&delivery); err != nil {
+			if !errors.Is(err, models.ErrWebhookNotFound) {
+				log.Println("Erro ao registrar a entrega de webhook:", err)
+			}
+			continue
+		}
+
+		d.start(delivery)
+	}
+}
+
+// Close interrompe as esperas e os envios em andamento e aguarda o fim das
+// goroutines. Os eventos ainda na fila têm as entregas registradas como
+// pendentes, sem envio; os publicados depois disso são descartados.
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	d.cancel()
+	d.wg.Wait()
+}
+
+// start envia a entrega em uma nova goroutine, a menos que o dispatcher já
+// tenha sido fechado.
+func (d *Dispatcher) start(delivery models.WebhookDelivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.deliver(delivery)
+	}()
+}
+
+// deliver tenta enviar a entrega até ela ser aceita ou esgotar as tentativas,
+// gravando o resultado de cada tentativa.
+func (d *Dispatcher) deliver(delivery models.WebhookDelivery) {
+	for delivery.Status == models.DeliveryPending {
+		if delivery.Attempts > 0 {
+			timer := time.NewTimer(d.backoff(delivery.Attempts))
+			select {
+			case <-d.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		statusCode, err := d.attempt(delivery)
+		if errors.Is(err, models.ErrWebhookNotFound) {
+			// O webhook foi excluído e levou junto o registro da entrega.
+			return
+		}
+		if d.ctx.Err() != nil {
+			// Interrompida pelo Close: não conta como tentativa.
+			return
+		}
+
+		delivery.Attempts++
+		delivery.LastStatusCode = statusCode
+		delivery.LastError = ""
+		delivery.UpdatedAt = time.Now().Unix()
+		switch {
+		case err == nil:
+			delivery.Status = models.DeliveryDelivered
+		case delivery.Attempts >= d.MaxAttempts:
+			delivery.Status = models.DeliveryFailed
+			delivery.LastError = err.Error()
+		default:
+			delivery.LastError = err.Error()
+		}
+
+		if err := d.store.UpdateDelivery(delivery); err != nil {
+			if !errors.Is(err, models.ErrDeliveryNotFound) {
+				log.Println("Erro ao registrar a tentativa de entrega de webhook:", err)
+			}
+			return
+		}
+	}
+}
+
+// attempt faz uma tentativa de entrega, devolvendo o status da resposta, se
+// houver. Respostas fora da faixa 2xx são erros.
+func (d *Dispatcher) attempt(delivery models.WebhookDelivery) (*int, error) {
+	w, err := d.store.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, w.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(SignatureHeader, Sign(w.Secret, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Lê um pouco da resposta para que a conexão possa ser reaproveitada.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	statusCode := resp.StatusCode
+	if statusCode < 200 || statusCode > 299 {
+		return &statusCode, fmt.Errorf("o receptor respondeu com status %d", statusCode)
+	}
+
+	return &statusCode, nil
+}
+
+// backoff calcula a espera depois da tentativa de número attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.Backoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+
+	return min(wait, d.MaxBackoff)
+}
+
+// Sign calcula a assinatura do corpo com o segredo do webhook, no formato do
+// cabeçalho SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify confere a assinatura recebida em SignatureHeader, em tempo
+// constante. É o que um receptor deve fazer antes de confiar no corpo.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// NewSecret gera um segredo aleatório para assinar as entregas de um webhook.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
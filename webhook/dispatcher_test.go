@@ -0,0 +1,301 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mr-utzig/planne-test/database/memory"
+	"github.com/mr-utzig/planne-test/models"
+)
+
+// receiver é um servidor de teste que guarda as requisições recebidas e
+// responde com os status de statuses, em ordem; depois deles, responde 200.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []received
+}
+
+// received é uma requisição recebida pelo receiver.
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rec := &receiver{statuses: statuses}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, received{r.Header.Clone(), body})
+		status := http.StatusOK
+		if len(rec.statuses) > 0 {
+			status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+		}
+		rec.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rec.Close)
+
+	return rec
+}
+
+// received devolve uma cópia das requisições recebidas até agora.
+func (rec *receiver) received() []received {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return append([]received(nil), rec.requests...)
+}
+
+// newDispatcher cria um dispatcher com esperas curtas, fechado ao fim do teste.
+func newDispatcher(t *testing.T, store models.WebhookStore) *Dispatcher {
+	d := NewDispatcher(store)
+	d.Backoff = 10 * time.Millisecond
+	d.MaxBackoff = 40 * time.Millisecond
+	t.Cleanup(d.Close)
+
+	return d
+}
+
+// mustCreateWebhook cadastra um webhook e falha o teste em caso de erro.
+func mustCreateWebhook(t *testing.T, store models.WebhookStore, url string, events ...string) models.Webhook {
+	t.Helper()
+
+	w := models.Webhook{URL: url, Events: events, Secret: "s3cret"}
+	if err := store.CreateWebhook(&w); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	return w
+}
+
+// waitForStatus aguarda as entregas do webhook saírem de pending.
+func waitForStatus(t *testing.T, store models.WebhookStore, webhookID int, want int) []models.WebhookDelivery {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		deliveries, _ := store.ListDeliveries(webhookID)
+		done := 0
+		for _, d := range deliveries {
+			if d.Status != models.DeliveryPending {
+				done++
+			}
+		}
+		if done >= want || time.Now().After(deadline) {
+			return deliveries
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestPublishSignsAndDelivers verifica o corpo, os cabeçalhos e a assinatura
+// da entrega, e que só os webhooks que assinam o evento o recebem.
+func TestPublishSignsAndDelivers(t *testing.T) {
+	store := memory.New()
+	rec := newReceiver(t)
+	subscribed := mustCreateWebhook(t, store, rec.URL, models.EventFruitCreated)
+	other := mustCreateWebhook(t, store, rec.URL, models.EventBucketDeleted)
+
+	d := newDispatcher(t, store)
+	d.Publish(models.EventFruitCreated, models.Fruit{ID: 7, Name: "Apple"})
+
+	deliveries := waitForStatus(t, store, subscribed.ID, 1)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryDelivered || deliveries[0].Attempts != 1 ||
+		deliveries[0].LastStatusCode == nil || *deliveries[0].LastStatusCode != http.StatusOK {
+		t.Fatalf("Expected one delivered delivery. Got %+v", deliveries)
+	}
+
+	requests := rec.received()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request. Got %d", len(requests))
+	}
+
+	req := requests[0]
+	if !Verify("s3cret", req.body, req.header.Get(SignatureHeader)) {
+		t.Errorf("Expected a valid signature. Got %q", req.header.Get(SignatureHeader))
+	}
+	if Verify("other", req.body, req.header.Get(SignatureHeader)) {
+		t.Errorf("Expected the signature to depend on the secret")
+	}
+	if req.header.Get(EventHeader) != models.EventFruitCreated || req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers %v", req.header)
+	}
+
+	var envelope struct {
+		Event string       `json:"event"`
+		Data  models.Fruit `json:"data"`
+	}
+	if err := json.Unmarshal(req.body, &envelope); err != nil || envelope.Event != models.EventFruitCreated || envelope.Data.ID != 7 {
+		t.Errorf("Unexpected body %s (%v)", req.body, err)
+	}
+
+	if deliveries, _ := store.ListDeliveries(other.ID); len(deliveries) != 0 {
+		t.Errorf("Expected no deliveries to an unsubscribed webhook. Got %+v", deliveries)
+	}
+}
+
+// TestDeliveryRetriesWithBackoff verifica as novas tentativas após falhas e o
+// registro do resultado de cada uma.
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+	store := memory.New()
+	rec := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	w := mustCreateWebhook(t, store, rec.URL, models.EventBucketFull)
+
+	d := newDispatcher(t, store)
+	d.Publish(models.EventBucketFull, map[string]int{"id": 1})
+
+	deliveries := waitForStatus(t, store, w.ID, 1)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryDelivered || deliveries[0].Attempts != 3 || deliveries[0].LastError != "" {
+		t.Fatalf("Expected delivery on the third attempt. Got %+v", deliveries)
+	}
+
+	requests := rec.received()
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests. Got %d", len(requests))
+	}
+
+	// Todas as tentativas são a mesma entrega, com o mesmo corpo.
+	for _, req := range requests[1:] {
+		if req.header.Get(DeliveryHeader) != requests[0].header.Get(DeliveryHeader) || string(req.body) != string(requests[0].body) {
+			t.Errorf("Expected retries to repeat the delivery. Got %v", req.header)
+		}
+	}
+}
+
+// TestDeliveryFailsAfterMaxAttempts verifica que a entrega desiste após o
+// limite de tentativas, guardando o último erro.
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	store := memory.New()
+	rec := newReceiver(t, 500, 500, 500, 500)
+	w := mustCreateWebhook(t, store, rec.URL, models.EventFruitExpired)
+
+	d := newDispatcher(t, store)
+	d.MaxAttempts = 3
+	d.Publish(models.EventFruitExpired, nil)
+
+	deliveries := waitForStatus(t, store, w.ID, 1)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryFailed || deliveries[0].Attempts != 3 ||
+		deliveries[0].LastStatusCode == nil || *deliveries[0].LastStatusCode != 500 || deliveries[0].LastError == "" {
+		t.Fatalf("Expected a failed delivery after 3 attempts. Got %+v", deliveries)
+	}
+
+	if n := len(rec.received()); n != 3 {
+		t.Errorf("Expected 3 requests. Got %d", n)
+	}
+}
+
+// TestLoadResumesPendingDeliveries verifica que as entregas pendentes no
+// store são enviadas por um novo dispatcher.
+func TestLoadResumesPendingDeliveries(t *testing.T) {
+	store := memory.New()
+	rec := newReceiver(t)
+	w := mustCreateWebhook(t, store, rec.URL, models.EventFruitRemoved)
+
+	pending := models.WebhookDelivery{WebhookID: w.ID, Event: models.EventFruitRemoved, Payload: []byte(`{"event":"fruit.removed"}`), Status: models.DeliveryPending, Attempts: 1}
+	if err := store.CreateDelivery(&pending); err != nil {
+		t.Fatalf("CreateDelivery: %v", err)
+	}
+
+	d := newDispatcher(t, store)
+	if err := d.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	deliveries := waitForStatus(t, store, w.ID, 1)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryDelivered || deliveries[0].Attempts != 2 {
+		t.Fatalf("Expected the pending delivery to be resumed. Got %+v", deliveries)
+	}
+}
+
+// blockingStore segura ListWebhooks até release ser fechado.
+type blockingStore struct {
+	models.WebhookStore
+	release chan struct{}
+}
+
+func (s blockingStore) ListWebhooks() ([]models.Webhook, error) {
+	<-s.release
+	return s.WebhookStore.ListWebhooks()
+}
+
+// TestPublishDoesNotBlock verifica que Publish retorna sem esperar o store e
+// que Close registra como pendentes as entregas dos eventos ainda na fila.
+func TestPublishDoesNotBlock(t *testing.T) {
+	store := memory.New()
+	rec := newReceiver(t)
+	w := mustCreateWebhook(t, store, rec.URL, models.EventBucketDeleted)
+
+	release := make(chan struct{})
+	d := NewDispatcher(blockingStore{store, release})
+
+	published := make(chan struct{})
+	go func() {
+		d.Publish(models.EventBucketDeleted, map[string]int{"id": 1})
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		close(release)
+		t.Fatal("Expected Publish to return while the store is blocked")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+
+	// Close só libera o store depois de marcar o dispatcher como fechado.
+	for {
+		d.mu.Lock()
+		done := d.closed
+		d.mu.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-closed
+
+	deliveries, _ := store.ListDeliveries(w.ID)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryPending || deliveries[0].Attempts != 0 {
+		t.Fatalf("Expected one pending delivery. Got %+v", deliveries)
+	}
+	if n := len(rec.received()); n != 0 {
+		t.Errorf("Expected no requests after Close. Got %d", n)
+	}
+
+	// Depois de fechado, os eventos são descartados.
+	d.Publish(models.EventBucketDeleted, nil)
+	if deliveries, _ := store.ListDeliveries(w.ID); len(deliveries) != 1 {
+		t.Errorf("Expected events after Close to be dropped. Got %+v", deliveries)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(memory.New())
+	t.Cleanup(d.Close)
+	d.Backoff = time.Second
+	d.MaxBackoff = 5 * time.Second
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := d.backoff(i + 1); got != w {
+			t.Errorf("backoff(%d): Expected %s. Got %s", i+1, w, got)
+		}
+	}
+}